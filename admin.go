@@ -0,0 +1,295 @@
+package main
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/config"
+	"adbiz_backend/handlers"
+	"adbiz_backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const commandTimeout = 5 * time.Minute
+
+// setupRedis connects to Redis and applies the key names and limits of the cache
+func setupRedis(cfg *config.Config) error {
+	if err := config.SetupRedis(cfg); err != nil {
+		return err
+	}
+	cache.Configure(cfg)
+	return nil
+}
+
+// setupStores connects to the database and Redis for an admin command
+func setupStores(cfg *config.Config) error {
+	if err := config.SetupDatabase(cfg); err != nil {
+		return fmt.Errorf("failed to setup database: %w", err)
+	}
+	if err := setupRedis(cfg); err != nil {
+		return fmt.Errorf("failed to setup Redis: %w", err)
+	}
+	return nil
+}
+
+func closeStores() {
+	config.CloseRedis()
+	if sqlDB, err := config.Db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// withStores runs fn with the database and Redis set up and reports its error
+func withStores(cfg *config.Config, fn func(ctx context.Context) error) int {
+	if err := setupStores(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeStores()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// findUser looks a user up by mobile number, including deactivated ones
+func findUser(ctx context.Context, mobileNumber string) (*models.User, error) {
+	var user models.User
+	err := config.Db.WithContext(ctx).Unscoped().Where("mobile_number = ?", mobileNumber).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("no user with mobile number %s", mobileNumber)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return &user, nil
+}
+
+// runCreateAdmin creates an admin account, or promotes an existing user with --promote
+func runCreateAdmin(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	name := fs.String("name", "", "display name of a new admin")
+	promote := fs.Bool("promote", false, "make an existing user an admin")
+	mobileNumber, ok := parseWithArg(fs, args, "create-admin <mobile> --name NAME [--promote]")
+	if !ok {
+		return 2
+	}
+
+	return withStores(cfg, func(ctx context.Context) error {
+		user, err := findUser(ctx, mobileNumber)
+		if err == nil {
+			if user.Role == models.RoleAdmin {
+				fmt.Printf("User %s is already an admin\n", mobileNumber)
+				return nil
+			}
+			if !*promote {
+				return fmt.Errorf("user %s exists as a %s, pass --promote to make them an admin", mobileNumber, user.Role)
+			}
+			if user.DeletedAt.Valid {
+				return fmt.Errorf("user %s is deactivated, reactivate them first", mobileNumber)
+			}
+			if err := config.Db.WithContext(ctx).Model(user).Update("role", models.RoleAdmin).Error; err != nil {
+				return fmt.Errorf("failed to promote user: %w", err)
+			}
+			// Tokens carry the role, so the new one applies from the next login
+			if err := cache.InvalidateUserCache(ctx, user.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to invalidate user cache: %v\n", err)
+			}
+			fmt.Printf("Promoted %s to admin\n", mobileNumber)
+			return nil
+		}
+
+		if *name == "" {
+			return fmt.Errorf("--name is required for a new admin")
+		}
+		admin := models.User{MobileNumber: mobileNumber, Name: *name, Role: models.RoleAdmin}
+		if err := config.Db.WithContext(ctx).Create(&admin).Error; err != nil {
+			return fmt.Errorf("failed to create admin: %w", err)
+		}
+		fmt.Printf("Created admin %s (public ID %s); they sign in with an OTP sent to the number\n", mobileNumber, admin.PublicID)
+		return nil
+	})
+}
+
+// userReport is what "user show" prints
+type userReport struct {
+	User           *models.User `json:"user"`
+	Deactivated    bool         `json:"deactivated"`
+	Shop           *models.Shop `json:"shop,omitempty"`
+	Followers      int64        `json:"followers"`
+	Following      int64        `json:"following"`
+	ActiveSessions int64        `json:"active_sessions"`
+}
+
+// runUser handles "user show|deactivate|reactivate <mobile>"
+func runUser(cfg *config.Config, args []string) int {
+	const userUsage = "user show|deactivate|reactivate <mobile>"
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+userUsage)
+		return 2
+	}
+	action, mobileNumber := args[0], args[1]
+
+	switch action {
+	case "show":
+		return withStores(cfg, func(ctx context.Context) error {
+			user, err := findUser(ctx, mobileNumber)
+			if err != nil {
+				return err
+			}
+			db := config.Db.WithContext(ctx)
+			report := userReport{User: user, Deactivated: user.DeletedAt.Valid}
+
+			var shop models.Shop
+			if err := db.Unscoped().Where("user_id = ?", user.ID).First(&shop).Error; err == nil {
+				report.Shop = &shop
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to find shop: %w", err)
+			}
+			if err := db.Model(&models.Follow{}).Where("followee_id = ?", user.ID).Count(&report.Followers).Error; err != nil {
+				return err
+			}
+			if err := db.Model(&models.Follow{}).Where("follower_id = ?", user.ID).Count(&report.Following).Error; err != nil {
+				return err
+			}
+			if err := db.Model(&models.Session{}).
+				Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now().UTC()).
+				Count(&report.ActiveSessions).Error; err != nil {
+				return err
+			}
+
+			out := json.NewEncoder(os.Stdout)
+			out.SetIndent("", "  ")
+			return out.Encode(report)
+		})
+
+	case "deactivate":
+		return withStores(cfg, func(ctx context.Context) error {
+			user, err := findUser(ctx, mobileNumber)
+			if err != nil {
+				return err
+			}
+			if user.DeletedAt.Valid {
+				return fmt.Errorf("user %s is already deactivated", mobileNumber)
+			}
+			if err := handlers.DeactivateUser(ctx, config.Db, user); err != nil {
+				return fmt.Errorf("failed to deactivate user: %w", err)
+			}
+			fmt.Printf("Deactivated %s and revoked their sessions\n", mobileNumber)
+			return nil
+		})
+
+	case "reactivate":
+		return withStores(cfg, func(ctx context.Context) error {
+			user, err := findUser(ctx, mobileNumber)
+			if err != nil {
+				return err
+			}
+			if !user.DeletedAt.Valid {
+				return fmt.Errorf("user %s is already active", mobileNumber)
+			}
+			if err := handlers.ReactivateUserAccount(ctx, config.Db, user); err != nil {
+				return fmt.Errorf("failed to reactivate user: %w", err)
+			}
+			fmt.Printf("Reactivated %s\n", mobileNumber)
+			return nil
+		})
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+userUsage)
+	return 2
+}
+
+// runShop handles "shop reindex"
+func runShop(cfg *config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "reindex" {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend shop reindex")
+		return 2
+	}
+	return withStores(cfg, func(ctx context.Context) error {
+		n, err := handlers.ReindexShopLocations(ctx, config.Db)
+		if err != nil {
+			return fmt.Errorf("failed to reindex shop locations: %w", err)
+		}
+		fmt.Printf("Indexed %d shop locations\n", n)
+		return nil
+	})
+}
+
+// runCache handles "cache flush --prefix P [--force]"
+func runCache(cfg *config.Config, args []string) int {
+	const cacheUsage = "cache flush --prefix P [--force]"
+	if len(args) == 0 || args[0] != "flush" {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+cacheUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "delete keys starting with this prefix")
+	force := fs.Bool("force", false, "also delete sessions, refresh tokens and token revocations")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 || *prefix == "" {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+cacheUsage)
+		return 2
+	}
+
+	// Sign-in state shares prefixes such as "user:" with the caches, so it is skipped
+	// unless forced, and a prefix inside it is refused
+	var keep []string
+	if !*force {
+		keep = cache.SessionKeyspaces()
+		for _, keyspace := range keep {
+			if strings.HasPrefix(*prefix, keyspace) {
+				fmt.Fprintf(os.Stderr, "Refusing to flush %q: it holds sign-in state under %q; pass --force to delete it\n", *prefix, keyspace)
+				return 1
+			}
+		}
+	}
+
+	// Only Redis is needed; flushing must work even when the database is down
+	if err := setupRedis(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup Redis: %v\n", err)
+		return 1
+	}
+	defer config.CloseRedis()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	n, err := cache.FlushPrefix(ctx, *prefix, keep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to flush cache after deleting %d keys: %v\n", n, err)
+		return 1
+	}
+	fmt.Printf("Deleted %d keys starting with %q\n", n, *prefix)
+	return 0
+}
+
+// parseWithArg parses flags around a single positional argument, in either order
+func parseWithArg(fs *flag.FlagSet, args []string, usage string) (string, bool) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+usage)
+			return "", false
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend "+usage)
+		return "", false
+	}
+	return positional[0], true
+}
@@ -0,0 +1,123 @@
+// Package analytics counts shop and post engagement. Handlers call Track, which only
+// queues the event in memory; Run batches the queue into a Redis buffer and rolls the
+// buffer up into hourly and daily ShopStat and daily PostStat rows.
+package analytics
+
+import (
+	"adbiz_backend/cache"
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Metrics of an Event
+const (
+	ProfileView    = "profile_views"
+	PostImpression = "post_impressions"
+	PostClick      = "post_clicks"
+	Follow         = "follows"
+	Unfollow       = "unfollows"
+)
+
+const (
+	queueSize       = 10000
+	maxPending      = 100000 // Distinct counters kept in memory while Redis is unreachable
+	bufferInterval  = time.Second
+	rollupInterval  = time.Minute
+	staleBatchAge   = 10 * time.Minute
+	shutdownTimeout = 10 * time.Second
+)
+
+// Event is one occurrence of a metric for a shop and, for post metrics, one of its posts
+type Event struct {
+	Metric string
+	ShopID uint
+	PostID uint
+	At     time.Time
+}
+
+var (
+	queue   = make(chan Event, queueSize)
+	dropped atomic.Int64
+)
+
+// Track queues an event without blocking. Events are dropped when the queue is full.
+func Track(event Event) {
+	if event.ShopID == 0 {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	select {
+	case queue <- event:
+	default:
+		if dropped.Add(1)%1000 == 1 {
+			log.Printf("Analytics queue full, %d events dropped so far", dropped.Load())
+		}
+	}
+}
+
+// Run moves tracked events to Redis every second and rolls them up into the database
+// every minute until ctx is done, then flushes what is left and returns.
+func Run(ctx context.Context, db *gorm.DB) {
+	bufferTicker := time.NewTicker(bufferInterval)
+	defer bufferTicker.Stop()
+	rollupTicker := time.NewTicker(rollupInterval)
+	defer rollupTicker.Stop()
+
+	pending := make(map[string]int64)
+	for {
+		select {
+		case event := <-queue:
+			count(pending, event)
+
+		case <-bufferTicker.C:
+			pending = flushPending(ctx, pending)
+
+		case <-rollupTicker.C:
+			pending = flushPending(ctx, pending)
+			rollup(ctx, db)
+
+		case <-ctx.Done():
+			// Drain the queue and flush with a fresh context, ctx is already cancelled
+			for len(queue) > 0 {
+				count(pending, <-queue)
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			flushPending(flushCtx, pending)
+			rollup(flushCtx, db)
+			cancel()
+			return
+		}
+	}
+}
+
+// count adds an event to the pending counters. Post events count towards the shop as well.
+func count(pending map[string]int64, event Event) {
+	hour := event.At.UTC().Truncate(time.Hour).Unix()
+	pending[fmt.Sprintf("s:%d:%d:%s", event.ShopID, hour, event.Metric)]++
+	if event.PostID != 0 {
+		pending[fmt.Sprintf("p:%d:%d:%d:%s", event.PostID, event.ShopID, hour, event.Metric)]++
+	}
+}
+
+// flushPending adds the pending counters to the Redis buffer. On failure they are kept
+// for the next attempt unless too many have piled up.
+func flushPending(ctx context.Context, pending map[string]int64) map[string]int64 {
+	if len(pending) == 0 {
+		return pending
+	}
+	if err := cache.IncrAnalytics(ctx, pending); err != nil {
+		log.Printf("Failed to buffer analytics events: %v", err)
+		if len(pending) < maxPending {
+			return pending
+		}
+		log.Printf("Dropping %d analytics counters", len(pending))
+	}
+	return make(map[string]int64)
+}
@@ -0,0 +1,178 @@
+package analytics
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const rollupBatchSize = 500
+
+type shopStatKey struct {
+	shopID uint
+	period string
+	start  int64
+}
+
+type postStatKey struct {
+	postID uint
+	day    int64
+}
+
+// rollup claims the Redis buffer, plus batches left behind by failed rollups, and adds
+// them to the stats tables. A batch is deleted only after its transaction commits, so a
+// crash in between counts it twice rather than losing it.
+func rollup(ctx context.Context, db *gorm.DB) {
+	stale, err := cache.StaleAnalyticsBatches(ctx, staleBatchAge)
+	if err != nil {
+		log.Printf("Failed to list stale analytics batches: %v", err)
+	}
+
+	for _, key := range append([]string{cache.AnalyticsBufferKey}, stale...) {
+		claimed, err := cache.ClaimAnalyticsBatch(ctx, key)
+		if err != nil {
+			log.Printf("Failed to claim analytics batch %s: %v", key, err)
+			continue
+		}
+		if claimed == "" {
+			continue
+		}
+		if err := applyBatch(ctx, db, claimed); err != nil {
+			log.Printf("Failed to roll up analytics batch %s: %v", claimed, err)
+			continue
+		}
+		if err := cache.DeleteAnalyticsBatch(ctx, claimed); err != nil {
+			log.Printf("Failed to delete analytics batch %s: %v", claimed, err)
+		}
+	}
+}
+
+// applyBatch upserts the counters of a claimed batch into the stats tables
+func applyBatch(ctx context.Context, db *gorm.DB, key string) error {
+	counts, err := cache.GetAnalyticsBatch(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	shopStats := make(map[shopStatKey]*models.ShopStat)
+	postStats := make(map[postStatKey]*models.PostStat)
+	for field, n := range counts {
+		parts := strings.Split(field, ":")
+		switch {
+		case len(parts) == 4 && parts[0] == "s":
+			shopID, hour, err := parseIDAndHour(parts[1], parts[2])
+			if err != nil {
+				continue
+			}
+			day := time.Unix(hour, 0).UTC().Truncate(24 * time.Hour).Unix()
+			addShopStat(shopStats, shopStatKey{shopID, models.StatPeriodHour, hour}, parts[3], n)
+			addShopStat(shopStats, shopStatKey{shopID, models.StatPeriodDay, day}, parts[3], n)
+
+		case len(parts) == 5 && parts[0] == "p":
+			postID, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			shopID, hour, err := parseIDAndHour(parts[2], parts[3])
+			if err != nil {
+				continue
+			}
+			day := time.Unix(hour, 0).UTC().Truncate(24 * time.Hour).Unix()
+			addPostStat(postStats, postStatKey{uint(postID), day}, shopID, parts[4], n)
+		}
+	}
+
+	shopRows := make([]*models.ShopStat, 0, len(shopStats))
+	for _, stat := range shopStats {
+		shopRows = append(shopRows, stat)
+	}
+	postRows := make([]*models.PostStat, 0, len(postStats))
+	for _, stat := range postStats {
+		postRows = append(postRows, stat)
+	}
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(shopRows) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "shop_id"}, {Name: "period"}, {Name: "bucket_start"}},
+				DoUpdates: incrementColumns("shop_stats", "profile_views", "post_impressions", "post_clicks", "follows", "unfollows"),
+			}).CreateInBatches(shopRows, rollupBatchSize).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(postRows) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "post_id"}, {Name: "day"}},
+				DoUpdates: incrementColumns("post_stats", "impressions", "clicks"),
+			}).CreateInBatches(postRows, rollupBatchSize).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func parseIDAndHour(id, hour string) (uint, int64, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	h, err := strconv.ParseInt(hour, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(n), h, nil
+}
+
+func addShopStat(stats map[shopStatKey]*models.ShopStat, key shopStatKey, metric string, n int64) {
+	stat, ok := stats[key]
+	if !ok {
+		stat = &models.ShopStat{ShopID: key.shopID, Period: key.period, BucketStart: time.Unix(key.start, 0).UTC()}
+		stats[key] = stat
+	}
+	switch metric {
+	case ProfileView:
+		stat.ProfileViews += n
+	case PostImpression:
+		stat.PostImpressions += n
+	case PostClick:
+		stat.PostClicks += n
+	case Follow:
+		stat.Follows += n
+	case Unfollow:
+		stat.Unfollows += n
+	}
+}
+
+func addPostStat(stats map[postStatKey]*models.PostStat, key postStatKey, shopID uint, metric string, n int64) {
+	stat, ok := stats[key]
+	if !ok {
+		stat = &models.PostStat{PostID: key.postID, Day: time.Unix(key.day, 0).UTC(), ShopID: shopID}
+		stats[key] = stat
+	}
+	switch metric {
+	case PostImpression:
+		stat.Impressions += n
+	case PostClick:
+		stat.Clicks += n
+	}
+}
+
+// incrementColumns adds the inserted values to the existing row on conflict
+func incrementColumns(table string, columns ...string) clause.Set {
+	values := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		values[column] = gorm.Expr(fmt.Sprintf("%s.%s + excluded.%s", table, column, column))
+	}
+	return clause.Assignments(values)
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AnalyticsBufferKey is the hash of analytics counters not yet rolled up into the database.
+// Batches claimed for a rollup are renamed to AnalyticsBufferKey + ":claimed:<unix nanos>".
+var AnalyticsBufferKey = "analytics:buffer"
+
+// IncrAnalytics adds counts to the analytics buffer
+func IncrAnalytics(ctx context.Context, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	_, err := config.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for field, n := range counts {
+			pipe.HIncrBy(ctx, AnalyticsBufferKey, field, n)
+		}
+		return nil
+	})
+	return err
+}
+
+// ClaimAnalyticsBatch moves the analytics buffer, or a batch claimed earlier, to a new
+// claimed key so no other instance rolls it up. It returns "" when there is nothing to claim.
+func ClaimAnalyticsBatch(ctx context.Context, key string) (string, error) {
+	claimed := fmt.Sprintf("%s:claimed:%d", AnalyticsBufferKey, time.Now().UnixNano())
+	err := config.RedisClient.Rename(ctx, key, claimed).Err()
+	if err != nil {
+		// The key is gone when it was empty or another instance claimed it first
+		if strings.Contains(err.Error(), "no such key") {
+			return "", nil
+		}
+		return "", err
+	}
+	return claimed, nil
+}
+
+// StaleAnalyticsBatches returns claimed batches older than age, left behind by a failed rollup
+func StaleAnalyticsBatches(ctx context.Context, age time.Duration) ([]string, error) {
+	prefix := AnalyticsBufferKey + ":claimed:"
+	cutoff := time.Now().Add(-age).UnixNano()
+
+	var stale []string
+	iter := config.RedisClient.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		claimedAt, err := strconv.ParseInt(strings.TrimPrefix(iter.Val(), prefix), 10, 64)
+		if err == nil && claimedAt < cutoff {
+			stale = append(stale, iter.Val())
+		}
+	}
+	return stale, iter.Err()
+}
+
+// GetAnalyticsBatch returns the counters of a claimed batch
+func GetAnalyticsBatch(ctx context.Context, key string) (map[string]int64, error) {
+	values, err := config.RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(values))
+	for field, value := range values {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[field] = n
+	}
+	return counts, nil
+}
+
+// DeleteAnalyticsBatch removes a claimed batch once it is rolled up
+func DeleteAnalyticsBatch(ctx context.Context, key string) error {
+	return config.RedisClient.Del(ctx, key).Err()
+}
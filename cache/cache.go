@@ -6,32 +6,49 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 var (
-	PostCachePrefix     = os.Getenv("REDIS_POST_CACHE_PREFIX")
-	UserCachePrefix     = os.Getenv("REDIS_USER_CACHE_PREFIX")
-	TempUserInfoPrefix  = "temp:user:"
-	DefaultExpiration   = time.Duration(func() int {
-		exp, err := strconv.Atoi(os.Getenv("REDIS_CACHE_EXPIRATION"))
-		if err != nil || exp <= 0 {
-			return 30 // Default to 30 minutes if not set or invalid
-		}
-		return exp
-	}()) * time.Minute
+	PostCachePrefix    = "post:"
+	UserCachePrefix    = "user:"
+	TempUserInfoPrefix = "temp:user:"
+	DefaultExpiration  = 30 * time.Minute
 	TempDataExpiration = 15 * time.Minute // Temporary data expires after 15 minutes
 )
 
+// Configure sets the key names, lifetimes and limits of the cache from cfg.
+// It is called once at startup, before the cache is used.
+func Configure(cfg *config.Config) {
+	PostCachePrefix = cfg.Cache.PostPrefix
+	UserCachePrefix = cfg.Cache.UserPrefix
+	DefaultExpiration = cfg.Cache.Expiration
+	ShopGeoKey = cfg.Cache.ShopGeoKey
+	AnalyticsBufferKey = cfg.Cache.AnalyticsKey
+	OTPExpiration = cfg.OTP.TTL
+	OTPResendCooldown = cfg.OTP.ResendCooldown
+	OTPMaxAttempts = int64(cfg.OTP.MaxAttempts)
+	FeedMaxLength = int64(cfg.Feed.MaxLength)
+	SessionSeenTTL = cfg.Auth.RefreshTokenTTL
+}
+
+// cachedUser adds the ID that User keeps out of its JSON, so that a cached user can be
+// saved back without creating a new row
+type cachedUser struct {
+	*models.User
+	ID uint `json:"id"`
+}
+
 // CacheUser stores a user in Redis cache
 func CacheUser(ctx context.Context, user *models.User) error {
 	if user == nil {
 		return fmt.Errorf("cannot cache nil user")
 	}
 
-	userJSON, err := json.Marshal(user)
+	userJSON, err := json.Marshal(cachedUser{User: user, ID: user.ID})
 	if err != nil {
 		return fmt.Errorf("failed to marshal user: %w", err)
 	}
@@ -48,12 +65,18 @@ func GetCachedUser(ctx context.Context, userID uint) (*models.User, error) {
 		return nil, err
 	}
 
-	var user models.User
-	if err := json.Unmarshal([]byte(userJSON), &user); err != nil {
+	cached := cachedUser{User: &models.User{}}
+	if err := json.Unmarshal([]byte(userJSON), &cached); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
 	}
+	// Entries written before the ID was kept cannot be saved back; treat them as missing
+	if cached.ID != userID {
+		return nil, redis.Nil
+	}
 
-	return &user, nil
+	user := cached.User
+	user.ID = cached.ID
+	return user, nil
 }
 
 // InvalidateUserCache removes a user from Redis cache
@@ -94,3 +117,50 @@ func RemoveTempUserInfo(ctx context.Context, mobileNumber string) error {
 	key := fmt.Sprintf("%s%s", TempUserInfoPrefix, mobileNumber)
 	return config.RedisClient.Del(ctx, key).Err()
 }
+
+// FlushPrefix deletes every key starting with prefix, except those starting with one of
+// keep, and returns how many were deleted
+func FlushPrefix(ctx context.Context, prefix string, keep []string) (int64, error) {
+	var deleted int64
+	iter := config.RedisClient.Scan(ctx, 0, prefix+"*", 500).Iterator()
+	batch := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		if hasAnyPrefix(iter.Val(), keep) {
+			continue
+		}
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			n, err := config.RedisClient.Unlink(ctx, batch...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	if len(batch) > 0 {
+		n, err := config.RedisClient.Unlink(ctx, batch...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping checks that Redis answers
+func Ping(ctx context.Context) error {
+	return config.RedisClient.Ping(ctx).Err()
+}
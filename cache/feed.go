@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	FeedPrefix         = "feed:"
+	FeedCelebritiesKey = "feed:celebrities"
+	FeedMaxLength      = int64(500)
+)
+
+// FeedEntry is a post in a user's timeline, scored by its publish time in milliseconds
+type FeedEntry struct {
+	PostID uint
+	Score  int64
+}
+
+// AddToFeeds pushes a post onto the timelines of the given users and trims them
+func AddToFeeds(ctx context.Context, userIDs []uint, entries ...FeedEntry) error {
+	if len(userIDs) == 0 || len(entries) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, len(entries))
+	for i, entry := range entries {
+		members[i] = redis.Z{Score: float64(entry.Score), Member: entry.PostID}
+	}
+
+	_, err := config.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			key := fmt.Sprintf("%s%d", FeedPrefix, userID)
+			pipe.ZAdd(ctx, key, members...)
+			// Keep only the newest FeedMaxLength entries
+			pipe.ZRemRangeByRank(ctx, key, 0, -FeedMaxLength-1)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetFeedEntries returns up to count timeline entries with a score at or below maxScore, newest first
+func GetFeedEntries(ctx context.Context, userID uint, maxScore int64, count int64) ([]FeedEntry, error) {
+	key := fmt.Sprintf("%s%d", FeedPrefix, userID)
+	max := "+inf"
+	if maxScore > 0 {
+		max = strconv.FormatInt(maxScore, 10)
+	}
+
+	results, err := config.RedisClient.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   max,
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FeedEntry, 0, len(results))
+	for _, z := range results {
+		postID, err := strconv.ParseUint(z.Member.(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, FeedEntry{PostID: uint(postID), Score: int64(z.Score)})
+	}
+	return entries, nil
+}
+
+// SetFeedCelebrity marks whether a user has too many followers for fan-out on write
+func SetFeedCelebrity(ctx context.Context, userID uint, celebrity bool) error {
+	if celebrity {
+		return config.RedisClient.SAdd(ctx, FeedCelebritiesKey, userID).Err()
+	}
+	return config.RedisClient.SRem(ctx, FeedCelebritiesKey, userID).Err()
+}
+
+// GetFeedCelebrities returns the users whose posts are pulled into feeds on read
+func GetFeedCelebrities(ctx context.Context) ([]uint, error) {
+	members, err := config.RedisClient.SMembers(ctx, FeedCelebritiesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		userID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		userIDs = append(userIDs, uint(userID))
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ShopGeoKey is the GEO set of active shops with coordinates, keyed by shop ID
+var ShopGeoKey = "shops:geo"
+
+// NearbyShop is a shop found by SearchNearbyShops
+type NearbyShop struct {
+	ShopID     uint
+	DistanceKm float64
+}
+
+// SetShopLocation adds or moves a shop in the GEO set
+func SetShopLocation(ctx context.Context, shopID uint, latitude, longitude float64) error {
+	return config.RedisClient.GeoAdd(ctx, ShopGeoKey, &redis.GeoLocation{
+		Name:      strconv.FormatUint(uint64(shopID), 10),
+		Latitude:  latitude,
+		Longitude: longitude,
+	}).Err()
+}
+
+// RemoveShopLocation drops shops from the GEO set, e.g. after they are deleted
+func RemoveShopLocation(ctx context.Context, shopIDs ...uint) error {
+	if len(shopIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(shopIDs))
+	for i, id := range shopIDs {
+		members[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return config.RedisClient.ZRem(ctx, ShopGeoKey, members...).Err()
+}
+
+// ShopLocation is a shop's position for RebuildShopLocations
+type ShopLocation struct {
+	ShopID    uint
+	Latitude  float64
+	Longitude float64
+}
+
+// RebuildShopLocations replaces the GEO set with the given shops. The new set is built
+// under a temporary key and renamed over the old one, so searches never see it half full.
+func RebuildShopLocations(ctx context.Context, shops []ShopLocation) error {
+	if len(shops) == 0 {
+		return config.RedisClient.Del(ctx, ShopGeoKey).Err()
+	}
+
+	tmpKey := ShopGeoKey + ":rebuild"
+	if err := config.RedisClient.Del(ctx, tmpKey).Err(); err != nil {
+		return err
+	}
+	for start := 0; start < len(shops); start += 1000 {
+		end := start + 1000
+		if end > len(shops) {
+			end = len(shops)
+		}
+		locations := make([]*redis.GeoLocation, 0, end-start)
+		for _, shop := range shops[start:end] {
+			locations = append(locations, &redis.GeoLocation{
+				Name:      strconv.FormatUint(uint64(shop.ShopID), 10),
+				Latitude:  shop.Latitude,
+				Longitude: shop.Longitude,
+			})
+		}
+		if err := config.RedisClient.GeoAdd(ctx, tmpKey, locations...).Err(); err != nil {
+			return err
+		}
+	}
+	return config.RedisClient.Rename(ctx, tmpKey, ShopGeoKey).Err()
+}
+
+// SearchNearbyShops returns up to count shops within radiusKm of the point, nearest first
+func SearchNearbyShops(ctx context.Context, latitude, longitude, radiusKm float64, count int) ([]NearbyShop, error) {
+	locations, err := config.RedisClient.GeoSearchLocation(ctx, ShopGeoKey, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Latitude:   latitude,
+			Longitude:  longitude,
+			Radius:     radiusKm,
+			RadiusUnit: "km",
+			Sort:       "ASC",
+			Count:      count,
+		},
+		WithDist: true,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	shops := make([]NearbyShop, 0, len(locations))
+	for _, location := range locations {
+		id, err := strconv.ParseUint(location.Name, 10, 64)
+		if err != nil {
+			continue // Not written by SetShopLocation
+		}
+		shops = append(shops, NearbyShop{ShopID: uint(id), DistanceKm: location.Dist})
+	}
+	return shops, nil
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var IdempotencyPrefix = "idem:"
+
+const idempotencyPending = "pending"
+
+// IdempotencyLease bounds how long a key stays reserved without a stored response, so a
+// request cut short by a crashed instance does not lock its key for the whole ttl
+var IdempotencyLease = 5 * time.Minute
+
+// IdempotentResponse is a stored response replayed for retried requests
+type IdempotentResponse struct {
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// ReserveIdempotencyKey claims a key for a new request for IdempotencyLease. If the key
+// was already used, it returns false and the stored response, which is nil while the
+// first request is still running.
+func ReserveIdempotencyKey(ctx context.Context, key string) (bool, *IdempotentResponse, error) {
+	key = IdempotencyPrefix + key
+	ok, err := config.RedisClient.SetNX(ctx, key, idempotencyPending, IdempotencyLease).Result()
+	if err != nil {
+		return false, nil, err
+	}
+	if ok {
+		return true, nil, nil
+	}
+
+	val, err := config.RedisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// Expired between the two calls, treat it as still running
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if val == idempotencyPending {
+		return false, nil, nil
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal([]byte(val), &resp); err != nil {
+		return false, nil, fmt.Errorf("failed to unmarshal idempotent response: %w", err)
+	}
+	return false, &resp, nil
+}
+
+// SaveIdempotentResponse stores the response for a reserved key
+func SaveIdempotentResponse(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+	return config.RedisClient.Set(ctx, IdempotencyPrefix+key, respJSON, ttl).Err()
+}
+
+// ReleaseIdempotencyKey frees a reserved key so the request can be retried
+func ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return config.RedisClient.Del(ctx, IdempotencyPrefix+key).Err()
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	OTPPrefix         = "otp:code:"
+	OTPCooldownPrefix = "otp:cooldown:"
+	OTPVerifiedPrefix = "otp:verified:"
+	OTPExpiration     = 5 * time.Minute
+	OTPResendCooldown = time.Minute
+	OTPMaxAttempts    = int64(5)
+)
+
+var (
+	ErrOTPNotFound        = errors.New("otp not found or expired")
+	ErrOTPTooManyAttempts = errors.New("too many otp attempts")
+)
+
+// AcquireOTPCooldown reserves the resend slot for a mobile number.
+// It returns the remaining cooldown when a code was sent too recently.
+func AcquireOTPCooldown(ctx context.Context, mobileNumber string) (time.Duration, error) {
+	key := OTPCooldownPrefix + mobileNumber
+	ok, err := config.RedisClient.SetNX(ctx, key, 1, OTPResendCooldown).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, nil
+	}
+
+	ttl, err := config.RedisClient.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	return ttl, nil
+}
+
+// StoreOTP saves the hashed code for a mobile number and resets its attempt counter
+func StoreOTP(ctx context.Context, mobileNumber, codeHash string) error {
+	key := OTPPrefix + mobileNumber
+	_, err := config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "hash", codeHash, "attempts", 0)
+		pipe.Expire(ctx, key, OTPExpiration)
+		return nil
+	})
+	return err
+}
+
+// GetOTPForAttempt counts a verification attempt and returns the stored hash.
+// The code is discarded once the attempt limit is exceeded.
+func GetOTPForAttempt(ctx context.Context, mobileNumber string) (string, error) {
+	key := OTPPrefix + mobileNumber
+
+	var hashCmd *redis.StringCmd
+	var attemptsCmd *redis.IntCmd
+	_, err := config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		hashCmd = pipe.HGet(ctx, key, "hash")
+		attemptsCmd = pipe.HIncrBy(ctx, key, "attempts", 1)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	codeHash, err := hashCmd.Result()
+	if err == redis.Nil {
+		// HIncrBy created an orphan key, drop it
+		config.RedisClient.Del(ctx, key)
+		return "", ErrOTPNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if attemptsCmd.Val() > OTPMaxAttempts {
+		config.RedisClient.Del(ctx, key)
+		return "", ErrOTPTooManyAttempts
+	}
+
+	return codeHash, nil
+}
+
+// RemoveOTP deletes the code for a mobile number after successful verification
+func RemoveOTP(ctx context.Context, mobileNumber string) error {
+	return config.RedisClient.Del(ctx, OTPPrefix+mobileNumber).Err()
+}
+
+// MarkMobileVerified records that ownership of a mobile number was proven,
+// so registration can continue without a second code
+func MarkMobileVerified(ctx context.Context, mobileNumber string) error {
+	key := fmt.Sprintf("%s%s", OTPVerifiedPrefix, mobileNumber)
+	return config.RedisClient.Set(ctx, key, 1, TempDataExpiration).Err()
+}
+
+// IsMobileVerified reports whether ownership of a mobile number was recently proven
+func IsMobileVerified(ctx context.Context, mobileNumber string) (bool, error) {
+	key := fmt.Sprintf("%s%s", OTPVerifiedPrefix, mobileNumber)
+	n, err := config.RedisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RemoveMobileVerified clears the verified marker once registration is complete
+func RemoveMobileVerified(ctx context.Context, mobileNumber string) error {
+	key := fmt.Sprintf("%s%s", OTPVerifiedPrefix, mobileNumber)
+	return config.RedisClient.Del(ctx, key).Err()
+}
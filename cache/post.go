@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"adbiz_backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// cachedPost adds the fields that Post keeps out of its JSON, which handlers need for
+// ownership checks and analytics
+type cachedPost struct {
+	*models.Post
+	ID     uint `json:"id"`
+	ShopID uint `json:"shop_id"`
+	UserID uint `json:"user_id"`
+}
+
+// CachePost stores a post in Redis cache
+func CachePost(ctx context.Context, post *models.Post) error {
+	if post == nil {
+		return fmt.Errorf("cannot cache nil post")
+	}
+
+	postJSON, err := json.Marshal(cachedPost{Post: post, ID: post.ID, ShopID: post.ShopID, UserID: post.UserID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal post: %w", err)
+	}
+
+	key := PostCachePrefix + post.PublicID
+	return config.RedisClient.Set(ctx, key, postJSON, DefaultExpiration).Err()
+}
+
+// GetCachedPost retrieves a post from Redis cache by public ID
+func GetCachedPost(ctx context.Context, publicID string) (*models.Post, error) {
+	key := PostCachePrefix + publicID
+	postJSON, err := config.RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cached := cachedPost{Post: &models.Post{}}
+	if err := json.Unmarshal([]byte(postJSON), &cached); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal post: %w", err)
+	}
+
+	post := cached.Post
+	post.ID, post.ShopID, post.UserID = cached.ID, cached.ShopID, cached.UserID
+	return post, nil
+}
+
+// InvalidatePostCache removes posts from Redis cache
+func InvalidatePostCache(ctx context.Context, publicIDs ...string) error {
+	if len(publicIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(publicIDs))
+	for i, publicID := range publicIDs {
+		keys[i] = PostCachePrefix + publicID
+	}
+	return config.RedisClient.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	SessionPrefix      = "session:"
+	UserSessionsPrefix = "user:sessions:"
+	SessionSeenPrefix  = "user:sessions:seen:"
+	RefreshPrefix      = "refresh:"
+	RefreshUsedPrefix  = "refresh:used:"
+	RevokedJTIPrefix   = "revoked:jti:"
+
+	// SessionSeenTTL matches the refresh token lifetime; no session outlives it
+	SessionSeenTTL = 30 * 24 * time.Hour
+)
+
+// SessionKeyspaces are the prefixes holding sign-in state. Deleting their keys signs
+// users out, hides live sessions from RevokeAllUserSessions and lets revoked access
+// tokens through again, so cache flushes leave them alone unless forced.
+func SessionKeyspaces() []string {
+	return []string{SessionPrefix, UserSessionsPrefix, RefreshPrefix, RevokedJTIPrefix}
+}
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// storeRefreshScript only attaches a refresh token to a session that still exists
+var storeRefreshScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "session_id", ARGV[1], "user_id", ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+redis.call("HSET", KEYS[2], "refresh", ARGV[4])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+redis.call("PEXPIRE", KEYS[3], ARGV[3])
+return 1
+`)
+
+// rotateRefreshScript atomically consumes a refresh token and leaves a marker
+// behind, so a second use of the same token can be detected as theft
+var rotateRefreshScript = redis.NewScript(`
+local data = redis.call("HGETALL", KEYS[1])
+if #data == 0 then
+	local sid = redis.call("GET", KEYS[2])
+	if sid then
+		return {"reused", sid}
+	end
+	return {"invalid"}
+end
+local ttl = redis.call("PTTL", KEYS[1])
+redis.call("DEL", KEYS[1])
+local fields = {}
+for i = 1, #data, 2 do
+	fields[data[i]] = data[i + 1]
+end
+if ttl > 0 then
+	redis.call("SET", KEYS[2], fields["session_id"], "PX", ttl)
+end
+return {"ok", fields["session_id"], fields["user_id"]}
+`)
+
+// CreateSession registers a new login session for a user
+func CreateSession(ctx context.Context, sessionID string, userID uint, ttl time.Duration) error {
+	key := SessionPrefix + sessionID
+	userKey := fmt.Sprintf("%s%d", UserSessionsPrefix, userID)
+	_, err := config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userID)
+		pipe.Expire(ctx, key, ttl)
+		pipe.SAdd(ctx, userKey, sessionID)
+		pipe.Expire(ctx, userKey, ttl)
+		return nil
+	})
+	return err
+}
+
+// StoreRefreshToken links a hashed refresh token to its session and extends the session lifetime.
+// It fails with ErrRefreshTokenInvalid if the session has been revoked in the meantime.
+func StoreRefreshToken(ctx context.Context, tokenHash, sessionID string, userID uint, ttl time.Duration) error {
+	keys := []string{
+		RefreshPrefix + tokenHash,
+		SessionPrefix + sessionID,
+		fmt.Sprintf("%s%d", UserSessionsPrefix, userID),
+	}
+	stored, err := storeRefreshScript.Run(ctx, config.RedisClient, keys, sessionID, userID, ttl.Milliseconds(), tokenHash).Int()
+	if err != nil {
+		return err
+	}
+	if stored == 0 {
+		return ErrRefreshTokenInvalid
+	}
+	return nil
+}
+
+// ConsumeRefreshToken invalidates a refresh token and returns the session it belongs to.
+// If the token was already used, the whole session is revoked and ErrRefreshTokenReused is returned.
+func ConsumeRefreshToken(ctx context.Context, tokenHash string) (string, uint, error) {
+	keys := []string{RefreshPrefix + tokenHash, RefreshUsedPrefix + tokenHash}
+	res, err := rotateRefreshScript.Run(ctx, config.RedisClient, keys).StringSlice()
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch res[0] {
+	case "ok":
+		userID, err := strconv.ParseUint(res[2], 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid user id in refresh token: %w", err)
+		}
+		return res[1], uint(userID), nil
+	case "reused":
+		return res[1], 0, ErrRefreshTokenReused
+	default:
+		return "", 0, ErrRefreshTokenInvalid
+	}
+}
+
+// GetSessionUserID returns the owner of an active session
+func GetSessionUserID(ctx context.Context, sessionID string) (uint, error) {
+	val, err := config.RedisClient.HGet(ctx, SessionPrefix+sessionID, "user_id").Result()
+	if err != nil {
+		return 0, err
+	}
+	userID, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user id in session: %w", err)
+	}
+	return uint(userID), nil
+}
+
+// IsTokenRevoked reports whether the session or the jti of an access token has been revoked
+func IsTokenRevoked(ctx context.Context, sessionID, jti string) (bool, error) {
+	var sessionCmd, jtiCmd *redis.IntCmd
+	_, err := config.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		sessionCmd = pipe.Exists(ctx, SessionPrefix+sessionID)
+		jtiCmd = pipe.Exists(ctx, RevokedJTIPrefix+jti)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return sessionCmd.Val() == 0 || jtiCmd.Val() > 0, nil
+}
+
+// RevokeJTI blocks a single access token until it would have expired anyway
+func RevokeJTI(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return config.RedisClient.Set(ctx, RevokedJTIPrefix+jti, 1, ttl).Err()
+}
+
+// RevokeSession deletes a session along with its current refresh token
+func RevokeSession(ctx context.Context, sessionID string) error {
+	key := SessionPrefix + sessionID
+	fields, err := config.RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	_, err = config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if refresh := fields["refresh"]; refresh != "" {
+			pipe.Del(ctx, RefreshPrefix+refresh)
+		}
+		if userID := fields["user_id"]; userID != "" {
+			pipe.SRem(ctx, UserSessionsPrefix+userID, sessionID)
+			pipe.ZRem(ctx, SessionSeenPrefix+userID, sessionID)
+		}
+		return nil
+	})
+	return err
+}
+
+// RevokeAllUserSessions deletes every session of a user
+func RevokeAllUserSessions(ctx context.Context, userID uint) error {
+	userKey := fmt.Sprintf("%s%d", UserSessionsPrefix, userID)
+	sessionIDs, err := config.RedisClient.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := RevokeSession(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+	return config.RedisClient.Del(ctx, userKey, fmt.Sprintf("%s%d", SessionSeenPrefix, userID)).Err()
+}
+
+// TouchSession records activity on a session. It writes to a separate sorted set
+// so that touching a revoked session can never bring it back. Sessions not seen for
+// SessionSeenTTL have expired and are dropped, and the set expires with the last one.
+func TouchSession(ctx context.Context, userID uint, sessionID string) error {
+	key := fmt.Sprintf("%s%d", SessionSeenPrefix, userID)
+	now := time.Now()
+	_, err := config.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Unix()), Member: sessionID})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-SessionSeenTTL).Unix(), 10))
+		pipe.Expire(ctx, key, SessionSeenTTL)
+		return nil
+	})
+	return err
+}
+
+// GetSessionsLastSeen returns the last activity time of each session known to Redis
+func GetSessionsLastSeen(ctx context.Context, userID uint, sessionIDs []string) (map[string]time.Time, error) {
+	lastSeen := make(map[string]time.Time, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return lastSeen, nil
+	}
+
+	key := fmt.Sprintf("%s%d", SessionSeenPrefix, userID)
+	scores, err := config.RedisClient.ZMScore(ctx, key, sessionIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, score := range scores {
+		if score > 0 {
+			lastSeen[sessionIDs[i]] = time.Unix(int64(score), 0).UTC()
+		}
+	}
+	return lastSeen, nil
+}
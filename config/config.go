@@ -0,0 +1,530 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Config is every setting of the application. It is loaded once at startup by Load
+// and handed to the constructors that need it.
+type Config struct {
+	Environment    string `yaml:"environment"`
+	MigrateOnStart bool   `yaml:"migrate_on_start"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Auth      AuthConfig      `yaml:"auth"`
+	OTP       OTPConfig       `yaml:"otp"`
+	SMS       SMSConfig       `yaml:"sms"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Feed      FeedConfig      `yaml:"feed"`
+	Media     MediaConfig     `yaml:"media"`
+}
+
+type ServerConfig struct {
+	Port         int           `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+
+	// ShutdownDelay is how long /readyz reports not ready before the server stops
+	// accepting connections, so load balancers stop sending traffic first
+	ShutdownDelay      time.Duration `yaml:"shutdown_delay"`
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+
+	// TrustedProxies are the IPs or CIDRs of the load balancers whose X-Forwarded-For
+	// is believed; with none, the client IP is the address of the connection
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// CursorSecret encrypts pagination cursors, which hold internal row IDs
+	CursorSecret string `yaml:"cursor_secret"`
+}
+
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+type RedisConfig struct {
+	URL          string        `yaml:"url"` // host:port
+	Password     string        `yaml:"password"`
+	PoolSize     int           `yaml:"pool_size"`
+	MinIdleConns int           `yaml:"min_idle_conns"`
+	MaxRetries   int           `yaml:"max_retries"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	PoolTimeout  time.Duration `yaml:"pool_timeout"`
+}
+
+// CacheConfig names the Redis keys the application keeps its data under
+type CacheConfig struct {
+	PostPrefix   string        `yaml:"post_prefix"`
+	UserPrefix   string        `yaml:"user_prefix"`
+	Expiration   time.Duration `yaml:"expiration"`
+	ShopGeoKey   string        `yaml:"shop_geo_key"`
+	AnalyticsKey string        `yaml:"analytics_key"`
+}
+
+type AuthConfig struct {
+	SigningKeyFile       string        `yaml:"signing_key_file"`
+	VerificationKeyFiles []string      `yaml:"verification_key_files"`
+	AccessTokenTTL       time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL      time.Duration `yaml:"refresh_token_ttl"`
+}
+
+type OTPConfig struct {
+	Secret         string        `yaml:"secret"`
+	TTL            time.Duration `yaml:"ttl"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	ResendCooldown time.Duration `yaml:"resend_cooldown"`
+}
+
+type SMSConfig struct {
+	Sender   string `yaml:"sender"` // "console" or "file"
+	FilePath string `yaml:"file_path"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerSecond int `yaml:"requests_per_second"`
+
+	// APIKeys are the keys of clients limited per X-API-Key rather than per IP
+	APIKeys []string `yaml:"api_keys"`
+}
+
+type FeedConfig struct {
+	FanOutThreshold int `yaml:"fan_out_threshold"`
+	MaxLength       int `yaml:"max_length"`
+}
+
+type MediaConfig struct {
+	Store       string        `yaml:"store"` // "local" or "s3"
+	LocalDir    string        `yaml:"local_dir"`
+	PublicURL   string        `yaml:"public_url"`
+	MaxUploadMB int           `yaml:"max_upload_mb"`
+	URLSecret   string        `yaml:"url_secret"`
+	URLTTL      time.Duration `yaml:"url_ttl"`
+	S3          S3Config      `yaml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"`
+}
+
+// Default returns the settings used for anything the file, environment and flags leave out
+func Default() *Config {
+	return &Config{
+		Environment: EnvProduction,
+		Server: ServerConfig{
+			Port:               8080,
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       15 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownDelay:      5 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{
+			URL:             "host=localhost user=postgres dbname=adbiz_main port=5432 sslmode=disable",
+			MaxIdleConns:    25,
+			MaxOpenConns:    200,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 10 * time.Minute,
+		},
+		Redis: RedisConfig{
+			URL:          "localhost:6379",
+			PoolSize:     100,
+			MinIdleConns: 10,
+			MaxRetries:   3,
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+			PoolTimeout:  4 * time.Second,
+		},
+		Cache: CacheConfig{
+			PostPrefix:   "post:",
+			UserPrefix:   "user:",
+			Expiration:   30 * time.Minute,
+			ShopGeoKey:   "shops:geo",
+			AnalyticsKey: "analytics:buffer",
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		OTP: OTPConfig{
+			TTL:            5 * time.Minute,
+			MaxAttempts:    5,
+			ResendCooldown: time.Minute,
+		},
+		SMS:       SMSConfig{Sender: "console", FilePath: "sms_outbox.log"},
+		RateLimit: RateLimitConfig{RequestsPerSecond: 10},
+		Feed:      FeedConfig{FanOutThreshold: 10000, MaxLength: 500},
+		Media: MediaConfig{
+			Store:       "local",
+			LocalDir:    "uploads",
+			PublicURL:   "/media",
+			MaxUploadMB: 10,
+			URLTTL:      time.Hour,
+			S3:          S3Config{UseSSL: true},
+		},
+	}
+}
+
+// Development reports whether the application runs on a developer machine
+func (c *Config) Development() bool {
+	return c.Environment == EnvDevelopment
+}
+
+// Production reports whether the application serves real users
+func (c *Config) Production() bool {
+	return c.Environment == EnvProduction
+}
+
+// Load builds the configuration from the defaults, the YAML file named by --config or
+// CONFIG_FILE, the environment and the global flags in args, each overriding the one
+// before. It returns the arguments after the flags, or flag.ErrHelp for -h.
+func Load(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("adbiz_backend", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "")
+	environment := fs.String("env", "", "")
+	port := fs.Int("port", 0, "")
+	databaseURL := fs.String("database-url", "", "")
+	redisURL := fs.String("redis-url", "", "")
+	migrateOnStart := fs.Bool("migrate-on-start", false, "")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var problems []error
+	for _, v := range cfg.envVars() {
+		if err := v.load(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "env":
+			cfg.Environment = *environment
+		case "port":
+			cfg.Server.Port = *port
+		case "database-url":
+			cfg.Database.URL = *databaseURL
+		case "redis-url":
+			cfg.Redis.URL = *redisURL
+		case "migrate-on-start":
+			cfg.MigrateOnStart = *migrateOnStart
+		}
+	})
+
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overrides settings with those in a YAML file. Unknown keys are an error so
+// that typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// envVar binds an environment variable to a setting. Durations take Go syntax such as
+// "90s"; a bare number is read in unit, matching names like OTP_TTL_SECONDS.
+type envVar struct {
+	name   string
+	target interface{}
+	unit   time.Duration
+}
+
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{name: "ENVIRONMENT", target: &c.Environment},
+		{name: "MIGRATE_ON_START", target: &c.MigrateOnStart},
+
+		{name: "PORT", target: &c.Server.Port},
+		{name: "READ_TIMEOUT", target: &c.Server.ReadTimeout, unit: time.Second},
+		{name: "WRITE_TIMEOUT", target: &c.Server.WriteTimeout, unit: time.Second},
+		{name: "IDLE_TIMEOUT", target: &c.Server.IdleTimeout, unit: time.Second},
+		{name: "SHUTDOWN_DELAY", target: &c.Server.ShutdownDelay, unit: time.Second},
+		{name: "HEALTH_CHECK_TIMEOUT", target: &c.Server.HealthCheckTimeout, unit: time.Second},
+		{name: "TRUSTED_PROXIES", target: &c.Server.TrustedProxies},
+		{name: "CURSOR_SECRET", target: &c.Server.CursorSecret},
+
+		{name: "DATABASE_URL", target: &c.Database.URL},
+		{name: "DB_MAX_IDLE_CONNS", target: &c.Database.MaxIdleConns},
+		{name: "DB_MAX_OPEN_CONNS", target: &c.Database.MaxOpenConns},
+		{name: "DB_CONN_MAX_LIFETIME", target: &c.Database.ConnMaxLifetime, unit: time.Minute},
+		{name: "DB_CONN_MAX_IDLE_TIME", target: &c.Database.ConnMaxIdleTime, unit: time.Minute},
+
+		{name: "REDIS_URL", target: &c.Redis.URL},
+		{name: "REDIS_PASSWORD", target: &c.Redis.Password},
+		{name: "REDIS_POOL_SIZE", target: &c.Redis.PoolSize},
+		{name: "REDIS_MIN_IDLE_CONNS", target: &c.Redis.MinIdleConns},
+		{name: "REDIS_MAX_RETRIES", target: &c.Redis.MaxRetries},
+		{name: "REDIS_DIAL_TIMEOUT", target: &c.Redis.DialTimeout, unit: time.Second},
+		{name: "REDIS_READ_TIMEOUT", target: &c.Redis.ReadTimeout, unit: time.Second},
+		{name: "REDIS_WRITE_TIMEOUT", target: &c.Redis.WriteTimeout, unit: time.Second},
+		{name: "REDIS_POOL_TIMEOUT", target: &c.Redis.PoolTimeout, unit: time.Second},
+
+		{name: "REDIS_POST_CACHE_PREFIX", target: &c.Cache.PostPrefix},
+		{name: "REDIS_USER_CACHE_PREFIX", target: &c.Cache.UserPrefix},
+		{name: "REDIS_CACHE_EXPIRATION", target: &c.Cache.Expiration, unit: time.Minute},
+		{name: "REDIS_SHOP_GEO_KEY", target: &c.Cache.ShopGeoKey},
+		{name: "REDIS_ANALYTICS_KEY", target: &c.Cache.AnalyticsKey},
+
+		{name: "JWT_SIGNING_KEY_FILE", target: &c.Auth.SigningKeyFile},
+		{name: "JWT_VERIFICATION_KEY_FILES", target: &c.Auth.VerificationKeyFiles},
+		{name: "ACCESS_TOKEN_TTL_MINUTES", target: &c.Auth.AccessTokenTTL, unit: time.Minute},
+		{name: "REFRESH_TOKEN_TTL_DAYS", target: &c.Auth.RefreshTokenTTL, unit: 24 * time.Hour},
+
+		{name: "OTP_SECRET", target: &c.OTP.Secret},
+		{name: "OTP_TTL_SECONDS", target: &c.OTP.TTL, unit: time.Second},
+		{name: "OTP_MAX_ATTEMPTS", target: &c.OTP.MaxAttempts},
+		{name: "OTP_RESEND_COOLDOWN_SECONDS", target: &c.OTP.ResendCooldown, unit: time.Second},
+
+		{name: "SMS_SENDER", target: &c.SMS.Sender},
+		{name: "SMS_FILE_PATH", target: &c.SMS.FilePath},
+
+		{name: "RATE_LIMIT_REQUESTS_PER_SECOND", target: &c.RateLimit.RequestsPerSecond},
+		{name: "RATE_LIMIT_API_KEYS", target: &c.RateLimit.APIKeys},
+
+		{name: "FEED_FANOUT_THRESHOLD", target: &c.Feed.FanOutThreshold},
+		{name: "FEED_MAX_LENGTH", target: &c.Feed.MaxLength},
+
+		{name: "MEDIA_STORE", target: &c.Media.Store},
+		{name: "MEDIA_LOCAL_DIR", target: &c.Media.LocalDir},
+		{name: "MEDIA_PUBLIC_URL", target: &c.Media.PublicURL},
+		{name: "MEDIA_MAX_UPLOAD_MB", target: &c.Media.MaxUploadMB},
+		{name: "MEDIA_URL_SECRET", target: &c.Media.URLSecret},
+		{name: "MEDIA_URL_TTL_MINUTES", target: &c.Media.URLTTL, unit: time.Minute},
+		{name: "S3_ENDPOINT", target: &c.Media.S3.Endpoint},
+		{name: "S3_REGION", target: &c.Media.S3.Region},
+		{name: "S3_BUCKET", target: &c.Media.S3.Bucket},
+		{name: "S3_ACCESS_KEY", target: &c.Media.S3.AccessKey},
+		{name: "S3_SECRET_KEY", target: &c.Media.S3.SecretKey},
+		{name: "S3_USE_SSL", target: &c.Media.S3.UseSSL},
+	}
+}
+
+// load sets the target from the environment; unset and empty variables keep its value
+func (v envVar) load() error {
+	raw := strings.TrimSpace(os.Getenv(v.name))
+	if raw == "" {
+		return nil
+	}
+
+	switch target := v.target.(type) {
+	case *string:
+		*target = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", v.name, raw)
+		}
+		*target = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", v.name, raw)
+		}
+		*target = b
+	case *time.Duration:
+		if n, err := strconv.Atoi(raw); err == nil && v.unit > 0 {
+			*target = time.Duration(n) * v.unit
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", v.name, raw)
+		}
+		*target = d
+	case *[]string:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target = list
+	default:
+		return fmt.Errorf("%s: unsupported setting type %T", v.name, v.target)
+	}
+	return nil
+}
+
+// Validate reports every setting that is out of range or missing for the environment
+func (c *Config) Validate() []error {
+	var problems []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == EnvDevelopment || c.Environment == EnvStaging || c.Environment == EnvProduction,
+		"environment must be development, staging or production, got %q", c.Environment)
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	check(c.Server.HealthCheckTimeout > 0, "server.health_check_timeout must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies: %q is not an IP or CIDR", proxy)
+	}
+	// Replicas must share the secret so that a cursor works on any of them
+	check(c.Server.CursorSecret != "" || c.Development(), "server.cursor_secret (CURSOR_SECRET) is required outside development")
+
+	check(c.Database.URL != "", "database.url is required")
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns must be positive, got %d", c.Database.MaxOpenConns)
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and max_open_conns, got %d", c.Database.MaxIdleConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+
+	check(c.Redis.URL != "", "redis.url is required")
+	check(c.Redis.PoolSize > 0, "redis.pool_size must be positive, got %d", c.Redis.PoolSize)
+	check(c.Redis.MinIdleConns >= 0 && c.Redis.MinIdleConns <= c.Redis.PoolSize,
+		"redis.min_idle_conns must be between 0 and pool_size, got %d", c.Redis.MinIdleConns)
+	check(c.Redis.MaxRetries >= -1, "redis.max_retries must be -1 (no retries) or more, got %d", c.Redis.MaxRetries)
+	check(c.Redis.DialTimeout > 0, "redis.dial_timeout must be positive")
+	check(c.Redis.ReadTimeout > 0, "redis.read_timeout must be positive")
+	check(c.Redis.WriteTimeout > 0, "redis.write_timeout must be positive")
+	check(c.Redis.PoolTimeout > 0, "redis.pool_timeout must be positive")
+
+	check(c.Cache.PostPrefix != "", "cache.post_prefix is required")
+	check(c.Cache.UserPrefix != "", "cache.user_prefix is required")
+	check(c.Cache.Expiration > 0, "cache.expiration must be positive")
+	check(c.Cache.ShopGeoKey != "", "cache.shop_geo_key is required")
+	check(c.Cache.AnalyticsKey != "", "cache.analytics_key is required")
+
+	check(c.Auth.AccessTokenTTL > 0, "auth.access_token_ttl must be positive")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "auth.refresh_token_ttl must be longer than access_token_ttl")
+	check(c.Auth.SigningKeyFile != "" || !c.Production(), "auth.signing_key_file (JWT_SIGNING_KEY_FILE) is required in production")
+
+	check(c.OTP.TTL > 0, "otp.ttl must be positive")
+	check(c.OTP.MaxAttempts > 0, "otp.max_attempts must be positive, got %d", c.OTP.MaxAttempts)
+	check(c.OTP.ResendCooldown > 0, "otp.resend_cooldown must be positive")
+	// Without a secret the stored OTP hashes are cheap to brute-force
+	check(c.OTP.Secret != "" || c.Development(), "otp.secret (OTP_SECRET) is required outside development")
+
+	check(c.SMS.Sender == "console" || c.SMS.Sender == "file", "sms.sender must be console or file, got %q", c.SMS.Sender)
+	// The console sender logs codes in plain text
+	check(c.SMS.Sender != "console" || c.Development(), "sms.sender (SMS_SENDER) must not be console outside development")
+	check(c.SMS.Sender != "file" || c.SMS.FilePath != "", "sms.file_path is required for the file sender")
+
+	check(c.RateLimit.RequestsPerSecond > 0, "rate_limit.requests_per_second must be positive, got %d", c.RateLimit.RequestsPerSecond)
+	for _, key := range c.RateLimit.APIKeys {
+		check(len(key) >= 16, "rate_limit.api_keys must be at least 16 characters long")
+	}
+
+	check(c.Feed.FanOutThreshold > 0, "feed.fan_out_threshold must be positive, got %d", c.Feed.FanOutThreshold)
+	check(c.Feed.MaxLength > 0, "feed.max_length must be positive, got %d", c.Feed.MaxLength)
+
+	check(c.Media.Store == "local" || c.Media.Store == "s3", "media.store must be local or s3, got %q", c.Media.Store)
+	check(c.Media.MaxUploadMB > 0, "media.max_upload_mb must be positive, got %d", c.Media.MaxUploadMB)
+	check(c.Media.URLTTL >= time.Second, "media.url_ttl must be at least a second")
+	if c.Media.Store == "local" {
+		check(c.Media.LocalDir != "", "media.local_dir is required for the local store")
+		check(c.Media.URLSecret != "" || !c.Production(), "media.url_secret (MEDIA_URL_SECRET) is required in production")
+	}
+	if c.Media.Store == "s3" {
+		check(c.Media.S3.Endpoint != "", "media.s3.endpoint is required for the s3 store")
+		check(c.Media.S3.Bucket != "", "media.s3.bucket is required for the s3 store")
+	}
+	return problems
+}
+
+const redacted = "[redacted]"
+
+var dsnPasswordPattern = regexp.MustCompile(`password=('[^']*'|\S+)`)
+
+// Redacted returns a copy with passwords and keys masked, for printing
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Auth.VerificationKeyFiles = append([]string(nil), c.Auth.VerificationKeyFiles...)
+	out.Server.TrustedProxies = append([]string(nil), c.Server.TrustedProxies...)
+	out.RateLimit.APIKeys = make([]string, len(c.RateLimit.APIKeys))
+	for i := range out.RateLimit.APIKeys {
+		out.RateLimit.APIKeys[i] = redacted
+	}
+	out.Database.URL = redactDSN(c.Database.URL)
+	for _, secret := range []*string{&out.Redis.Password, &out.Server.CursorSecret, &out.OTP.Secret, &out.Media.URLSecret, &out.Media.S3.SecretKey} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return &out
+}
+
+// redactDSN masks the password of a postgres:// URL or a key=value connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return strings.Replace(u.String(), "xxxxx", redacted, 1)
+		}
+		return dsn
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "password="+redacted)
+}
+
+// Dump writes the configuration as YAML, in the format Load reads from a file
+func (c *Config) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"adbiz_backend/metrics"
+	"adbiz_backend/migrations"
 	"adbiz_backend/models"
 
 	"gorm.io/driver/postgres"
@@ -15,17 +17,14 @@ import (
 
 var Db *gorm.DB
 
-func SetupDatabase() error {
+// ConnectDatabase opens the connection pool without touching the schema
+func ConnectDatabase(cfg *Config) error {
 	var err error
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		dsn = "host=localhost user=postgres password=Tosif@123 dbname=adbiz_main port=5432 sslmode=disable"
-	}
-	fmt.Println("DB Connection String:", dsn)
+	log.Printf("Connecting to database %s", redactDSN(cfg.Database.URL))
 
 	// Configure connection pool and logging with optimized settings
-	Db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger:      logger.Default.LogMode(getLogLevel()),
+	Db, err = gorm.Open(postgres.Open(cfg.Database.URL), &gorm.Config{
+		Logger:      logger.Default.LogMode(getLogLevel(cfg)),
 		PrepareStmt: true, // Enable prepared statement cache
 		NowFunc: func() time.Time { // Ensure consistent time handling
 			return time.Now().UTC()
@@ -43,51 +42,73 @@ func SetupDatabase() error {
 		return fmt.Errorf("failed to get database instance: %v", err)
 	}
 
-	// Set optimized connection pool parameters
-	sqlDB.SetMaxIdleConns(25)                  // Increased from 10
-	sqlDB.SetMaxOpenConns(200)                 // Increased from 100
-	sqlDB.SetConnMaxLifetime(30 * time.Minute) // Reduced from 1 hour for better resource management
-	sqlDB.SetConnMaxIdleTime(10 * time.Minute) // Add idle timeout
+	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
 
-	log.Println("Connected to database successfully")
+	// Export query timings and pool statistics on /metrics
+	if err := Db.Use(metrics.GormPlugin{}); err != nil {
+		return fmt.Errorf("failed to install metrics plugin: %v", err)
+	}
+	if err := metrics.RegisterDBStats(sqlDB); err != nil {
+		log.Printf("Failed to register database metrics: %v", err)
+	}
 
-	// Create channels for parallel migration processing
-	errorChan := make(chan error)
-	doneChan := make(chan bool)
+	log.Println("Connected to database successfully")
+	return nil
+}
 
-	// Run migrations in a separate goroutine
-	go func() {
-		log.Println("Running database migrations...")
-		err := Db.AutoMigrate(
-			&models.User{},
-			&models.Shop{},
-			&models.Fav1{},
-			&models.Fav2{},
-		)
+// SetupDatabase connects and checks the schema. Pending migrations are applied when
+// MigrateOnStart is set or in development, where AutoMigrate then also adds model
+// changes that have no migration yet; otherwise the server refuses to start until
+// "migrate up" has been run.
+func SetupDatabase(cfg *Config) error {
+	if err := ConnectDatabase(cfg); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	development := cfg.Development()
 
+	if development || cfg.MigrateOnStart {
+		applied, err := migrations.Up(ctx, Db, 0)
 		if err != nil {
-			errorChan <- fmt.Errorf("failed to run migrations: %v", err)
-			return
+			return fmt.Errorf("failed to run migrations: %v", err)
+		}
+		log.Printf("Applied %d database migrations", len(applied))
+	} else {
+		pending, err := migrations.Pending(ctx, Db)
+		if err != nil {
+			return fmt.Errorf("failed to check migrations: %v", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d database migrations are pending, run \"migrate up\" first", len(pending))
 		}
-		doneChan <- true
-	}()
-
-	// Wait for migration completion or timeout
-	select {
-	case err := <-errorChan:
-		return err
-	case <-doneChan:
-		log.Println("Database migrations completed successfully")
-	case <-time.After(2 * time.Minute): // Add timeout for migrations
-		return fmt.Errorf("migration timeout after 2 minutes")
 	}
 
+	if development {
+		log.Println("Running AutoMigrate for development...")
+		if err := Db.AutoMigrate(
+			&models.User{},
+			&models.Shop{},
+			&models.Session{},
+			&models.Follow{},
+			&models.Post{},
+			&models.ShopIDAlias{},
+			&models.Category{},
+			&models.Media{},
+			&models.ShopStat{},
+			&models.PostStat{},
+		); err != nil {
+			return fmt.Errorf("failed to run AutoMigrate: %v", err)
+		}
+	}
 	return nil
 }
 
 // getLogLevel returns the appropriate log level based on environment
-func getLogLevel() logger.LogLevel {
-	if os.Getenv("ENVIRONMENT") == "production" {
+func getLogLevel(cfg *Config) logger.LogLevel {
+	if cfg.Production() {
 		return logger.Silent
 	}
 	return logger.Info
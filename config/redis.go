@@ -1,11 +1,9 @@
 package config
 
 import (
+	"adbiz_backend/metrics"
 	"context"
 	"log"
-	"os"
-	"strconv"
-	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -16,34 +14,18 @@ var (
 )
 
 // SetupRedis initializes the Redis client with optimized connection pooling
-func SetupRedis() error {
-	// Default to localhost if REDIS_URL is not set
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		redisURL = "localhost:6379"
-	}
-
-	// Get Redis configuration from environment variables
-	poolSize, _ := strconv.Atoi(os.Getenv("REDIS_POOL_SIZE"))
-	minIdleConns, _ := strconv.Atoi(os.Getenv("REDIS_MIN_IDLE_CONNS"))
-	maxRetries, _ := strconv.Atoi(os.Getenv("REDIS_MAX_RETRIES"))
-	dialTimeout, _ := strconv.Atoi(os.Getenv("REDIS_DIAL_TIMEOUT"))
-	readTimeout, _ := strconv.Atoi(os.Getenv("REDIS_READ_TIMEOUT"))
-	writeTimeout, _ := strconv.Atoi(os.Getenv("REDIS_WRITE_TIMEOUT"))
-	poolTimeout, _ := strconv.Atoi(os.Getenv("REDIS_POOL_TIMEOUT"))
-
-	// Configure Redis client with settings from environment variables
+func SetupRedis(cfg *Config) error {
 	RedisClient = redis.NewClient(&redis.Options{
-		Addr:         redisURL,
-		Password:     "", // no password by default
-		DB:           0,  // use default DB
-		PoolSize:     poolSize,
-		MinIdleConns: minIdleConns,
-		MaxRetries:   maxRetries,
-		DialTimeout:  time.Duration(dialTimeout) * time.Second,
-		ReadTimeout:  time.Duration(readTimeout) * time.Second,
-		WriteTimeout: time.Duration(writeTimeout) * time.Second,
-		PoolTimeout:  time.Duration(poolTimeout) * time.Second,
+		Addr:         cfg.Redis.URL,
+		Password:     cfg.Redis.Password,
+		DB:           0, // use default DB
+		PoolSize:     cfg.Redis.PoolSize,
+		MinIdleConns: cfg.Redis.MinIdleConns,
+		MaxRetries:   cfg.Redis.MaxRetries,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.ReadTimeout,
+		WriteTimeout: cfg.Redis.WriteTimeout,
+		PoolTimeout:  cfg.Redis.PoolTimeout,
 	})
 
 	// Test the connection
@@ -52,6 +34,10 @@ func SetupRedis() error {
 		return err
 	}
 
+	if err := metrics.RegisterRedisPool(RedisClient); err != nil {
+		log.Printf("Failed to register Redis metrics: %v", err)
+	}
+
 	log.Printf("Connected to Redis: %s", pong)
 	return nil
 }
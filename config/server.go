@@ -2,22 +2,27 @@ package config
 
 import (
 	"log"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // setupServer configures and returns a Gin engine with optimized settings
-func SetupServer() *gin.Engine {
+func SetupServer(cfg *Config) *gin.Engine {
 	// Set Gin to release mode in production
-	if os.Getenv("ENVIRONMENT") != "development" {
+	if !cfg.Development() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	// Create a new Gin engine with custom configuration
 	r := gin.New()
 
+	// Only believe X-Forwarded-For from our own load balancers; anyone else could use it
+	// to pick the IP they are rate limited by
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
 	// Use custom recovery middleware
 	r.Use(gin.Recovery())
 
@@ -34,7 +39,7 @@ func SetupServer() *gin.Engine {
 		}
 
 		latency := time.Since(start)
-		if os.Getenv("ENVIRONMENT") == "production" {
+		if cfg.Production() {
 			if c.Writer.Status() >= 400 {
 				log.Printf("[ERROR] %s %s %d %s", c.Request.Method, path, c.Writer.Status(), latency)
 			}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"adbiz_backend/config"
+	"fmt"
+	"os"
+)
+
+// runConfig handles "config dump", printing the settings the other commands would use
+func runConfig(cfg *config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "dump" {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend config dump")
+		return 2
+	}
+	if err := cfg.Redacted().Dump(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
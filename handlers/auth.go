@@ -1,25 +1,51 @@
 package handlers
 
 import (
-	"os"
-	"strconv"
-	"time"
+	"adbiz_backend/config"
+	"adbiz_backend/models"
+	"adbiz_backend/sms"
+	"crypto/rand"
+	"log"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	db        *gorm.DB
-	rateLimit *time.Ticker
+	sms       sms.Sender
+	tokens    config.AuthConfig
+	otpSecret []byte
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	requestsPerSecond, _ := strconv.Atoi(os.Getenv("RATE_LIMIT_REQUESTS_PER_SECOND"))
-	if requestsPerSecond <= 0 {
-		requestsPerSecond = 10 // Default value
-	}
+func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		db:        db,
-		rateLimit: time.NewTicker(time.Second / time.Duration(requestsPerSecond)),
+		sms:       sms.NewSender(cfg.SMS.Sender, cfg.SMS.FilePath),
+		tokens:    cfg.Auth,
+		otpSecret: otpKey(cfg.OTP.Secret),
+	}
+}
+
+// otpKey returns the HMAC key for OTP hashes. Config validation requires a secret outside
+// development; there a random key is used instead, so pending codes stop working on restart.
+func otpKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	log.Printf("Warning: OTP_SECRET not set, using a temporary key")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate OTP key: %v", err)
+	}
+	return key
+}
+
+// isOwnerOrAdmin reports whether the authenticated user may act on the resources of userID
+func isOwnerOrAdmin(c *gin.Context, userID uint) bool {
+	if c.GetString("role") == models.RoleAdmin {
+		return true
 	}
+	authUserID, exists := c.Get("user_id")
+	return exists && authUserID.(uint) == userID
 }
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"adbiz_backend/cache"
 	"adbiz_backend/models"
+	"context"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,131 +15,80 @@ import (
 
 // DeleteUser handles the soft deletion of a user account
 func (h *AuthHandler) DeleteUser(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
+	// User resolved and authorized by RequireOwnerOrAdmin
+	user := c.MustGet("subject_user").(*models.User)
 
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Begin transaction
-	tx := h.db.Begin()
-
-	// Find the user by mobile number
-	var user models.User
-	if result := tx.Where("mobile_number = ? AND deleted_at IS NULL", mobileNumber).First(&user); result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Check if user is trying to delete their own data
-	if user.ID != authUserID.(uint) {
-		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own account"})
-		return
-	}
-
-	// Soft delete the user
-	now := time.Now()
-	if err := tx.Model(&user).Update("deleted_at", &now).Error; err != nil {
-		tx.Rollback()
+	if err := DeactivateUser(c.Request.Context(), h.db, user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user: " + err.Error()})
 		return
 	}
 
-	// If user is a seller, soft delete their shop as well
-	if user.Role == "seller" {
-		var shop models.Shop
-		if result := tx.Where("user_id = ? AND deleted_at IS NULL", user.ID).First(&shop); result.Error == nil {
-			if err := tx.Model(&shop).Update("deleted_at", &now).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated shop: " + err.Error()})
-				return
-			}
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User account deleted successfully",
 	})
 }
 
-// DeleteShop handles the soft deletion of a shop
-func (h *AuthHandler) DeleteShop(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
+// DeactivateUser soft deletes a user together with their shop, hides the shop from
+// cached reads and nearby search, and revokes the user's sessions
+func DeactivateUser(ctx context.Context, db *gorm.DB, user *models.User) error {
+	var shop models.Shop
+	now := time.Now()
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Soft delete the user
+		if err := tx.Model(user).Update("deleted_at", &now).Error; err != nil {
+			return err
+		}
 
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
+		// If user is a seller, soft delete their shop as well
+		if user.Role == models.RoleSeller {
+			if result := tx.Where("user_id = ? AND deleted_at IS NULL", user.ID).First(&shop); result.Error == nil {
+				if err := tx.Model(&shop).Update("deleted_at", &now).Error; err != nil {
+					return fmt.Errorf("failed to delete associated shop: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+	// Hide the deleted shop from cached reads and nearby search
+	if shop.ID != 0 {
+		invalidateShopPosts(ctx, db, shop.ID)
+		if err := cache.RemoveShopLocation(ctx, shop.ID); err != nil {
+			log.Printf("Failed to remove location of shop %d: %v", shop.ID, err)
+		}
 	}
 
-	// Begin transaction
-	tx := h.db.Begin()
-
-	// First, find the user by mobile number
-	var user models.User
-	if result := tx.Where("mobile_number = ? AND deleted_at IS NULL", mobileNumber).First(&user); result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
+	// Deleted accounts must not keep working through tokens issued earlier
+	if err := revokeAllSessions(ctx, db, user.ID); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", user.ID, err)
 	}
-
-	// Check if user is trying to delete their own shop
-	if user.ID != authUserID.(uint) {
-		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own shop"})
-		return
+	if err := cache.InvalidateUserCache(ctx, user.ID); err != nil {
+		log.Printf("Failed to invalidate user cache: %v", err)
 	}
+	return nil
+}
+
+// DeleteShop handles the soft deletion of a shop
+func (h *AuthHandler) DeleteShop(c *gin.Context) {
+	// User and shop resolved and authorized by RequireOwnerOrAdmin and LoadSubjectShop
+	user := c.MustGet("subject_user").(*models.User)
+	shop := c.MustGet("subject_shop").(*models.Shop)
 
 	// Check if user is a seller
-	if user.Role != "seller" {
-		tx.Rollback()
+	if user.Role != models.RoleSeller {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Only sellers can have shops"})
 		return
 	}
 
-	// Find the shop associated with this user
-	var shop models.Shop
-	if result := tx.Where("user_id = ? AND deleted_at IS NULL", user.ID).First(&shop); result.Error != nil {
-		tx.Rollback()
-		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find shop: " + result.Error.Error()})
-		}
-		return
-	}
+	// Begin transaction
+	tx := h.db.Begin()
 
 	// Soft delete the shop
 	now := time.Now()
-	if err := tx.Model(&shop).Update("deleted_at", &now).Error; err != nil {
+	if err := tx.Model(shop).Update("deleted_at", &now).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shop: " + err.Error()})
 		return
@@ -147,6 +100,12 @@ func (h *AuthHandler) DeleteShop(c *gin.Context) {
 		return
 	}
 
+	// Hide the deleted shop from cached reads and nearby search
+	invalidateShopPosts(c.Request.Context(), h.db, shop.ID)
+	if err := cache.RemoveShopLocation(c.Request.Context(), shop.ID); err != nil {
+		log.Printf("Failed to remove location of shop %d: %v", shop.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Shop deleted successfully",
 	})
@@ -8,38 +8,17 @@ import (
 )
 
 func (h *AuthHandler) GetFavs(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-
-	// Begin transaction
-	tx := h.db.Begin()
-
-	// Find the user by mobile number (including soft-deleted users)
-	var user models.User
-	if result := tx.Unscoped().Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
+	// User resolved and authorized by RequireOwnerOrAdmin
+	user := c.MustGet("subject_user").(*models.User)
 
 	// Fetch the user's favorites
-	var favs models.Fav1
-	if result := tx.Where("user_id = ?", user.ID).First(&favs); result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Favorites not found"})
+	favs, found, err := followingFavList(h.db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch favorites: " + err.Error()})
 		return
 	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Favorites not found"})
 		return
 	}
 
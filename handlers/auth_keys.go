@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"adbiz_backend/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingKey is a key pair used to sign or verify access tokens
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer // nil for verification-only keys
+	public  crypto.PublicKey
+}
+
+// keySet holds the key new tokens are signed with and every key tokens are still accepted from.
+// To rotate, publish the new public key in JWT_VERIFICATION_KEY_FILES first, then make it the
+// signing key and keep the old public key listed until its tokens have expired.
+var keySet struct {
+	sync.RWMutex
+	active *signingKey
+	verify map[string]*signingKey
+}
+
+// LoadSigningKeys reads the JWT signing key and the extra verification keys from disk.
+// Outside production a temporary Ed25519 key is generated when none is configured.
+func LoadSigningKeys(cfg *config.Config) error {
+	verify := make(map[string]*signingKey)
+
+	var active *signingKey
+	if path := cfg.Auth.SigningKeyFile; path != "" {
+		key, err := loadPrivateKey(path)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key: %w", err)
+		}
+		active = key
+	} else {
+		if cfg.Production() {
+			return errors.New("JWT_SIGNING_KEY_FILE must be set in production")
+		}
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		active, err = newSigningKey(private.Public(), private)
+		if err != nil {
+			return err
+		}
+		log.Printf("Warning: JWT_SIGNING_KEY_FILE not set, using a temporary key (tokens will not survive a restart)")
+	}
+	verify[active.kid] = active
+
+	for _, path := range cfg.Auth.VerificationKeyFiles {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return fmt.Errorf("failed to load verification key %s: %w", path, err)
+		}
+		if _, exists := verify[key.kid]; !exists {
+			verify[key.kid] = key
+		}
+	}
+
+	keySet.Lock()
+	keySet.active = active
+	keySet.verify = verify
+	keySet.Unlock()
+
+	log.Printf("Loaded JWT signing key %s (%s) with %d verification keys", active.kid, active.method.Alg(), len(verify))
+	return nil
+}
+
+// currentSigningKey returns the key new tokens are signed with
+func currentSigningKey() (*signingKey, error) {
+	keySet.RLock()
+	defer keySet.RUnlock()
+
+	if keySet.active == nil {
+		return nil, errors.New("signing keys not loaded")
+	}
+	return keySet.active, nil
+}
+
+// lookupVerificationKey returns the key for a token's kid header, checking that its alg matches
+func lookupVerificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no key id")
+	}
+
+	keySet.RLock()
+	key, ok := keySet.verify[kid]
+	keySet.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+
+	// Validate the signing method against the key instead of trusting the header
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// loadPrivateKey reads a PEM encoded RSA or Ed25519 private key
+func loadPrivateKey(path string) (*signingKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, errors.New("unsupported private key format")
+		}
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return newSigningKey(signer.Public(), signer)
+}
+
+// loadPublicKey reads a PEM encoded RSA or Ed25519 public key
+func loadPublicKey(path string) (*signingKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key format: %w", err)
+	}
+	return newSigningKey(public, nil)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return block, nil
+}
+
+// newSigningKey picks the algorithm for a key and derives its kid from the public key,
+// so every service computes the same id without extra configuration
+func newSigningKey(public crypto.PublicKey, private crypto.Signer) (*signingKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", public)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+
+	return &signingKey{
+		kid:     base64.RawURLEncoding.EncodeToString(sum[:12]),
+		method:  method,
+		private: private,
+		public:  public,
+	}, nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS publishes the public keys access tokens can be verified with
+func JWKS(c *gin.Context) {
+	keySet.RLock()
+	keys := make([]JWK, 0, len(keySet.verify))
+	for _, key := range keySet.verify {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	keySet.RUnlock()
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
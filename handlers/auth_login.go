@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"adbiz_backend/cache"
+	"adbiz_backend/metrics"
 	"adbiz_backend/models"
 	"log"
 	"net/http"
@@ -11,18 +12,20 @@ import (
 
 type LoginRequest struct {
 	MobileNumber string `json:"mobile_number" binding:"required"`
+	OTP          string `json:"otp" binding:"required"`
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !h.checkOTP(c, req.MobileNumber, req.OTP) {
+		return
+	}
+
 	var user models.User
 	if result := h.db.Where("mobile_number = ?", req.MobileNumber).First(&user); result.Error != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found with this mobile number"})
@@ -35,16 +38,23 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		log.Printf("Failed to cache user data: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := GenerateToken(&user)
+	// Generate JWT tokens
+	tokens, err := h.IssueTokens(c.Request.Context(), &user, deviceInfo(c))
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
+	metrics.Logins.WithLabelValues("login").Inc()
+
+	var urls mediaURLs
+	urls.user(&user)
+	urls.sign(c.Request.Context(), h.db)
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": user,
-		"token": token,
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
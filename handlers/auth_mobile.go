@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"adbiz_backend/cache"
+	"adbiz_backend/metrics"
 	"adbiz_backend/models"
 	"log"
 	"net/http"
@@ -11,26 +12,30 @@ import (
 
 type MobileVerificationRequest struct {
 	MobileNumber string `json:"mobile_number" binding:"required"`
+	OTP          string `json:"otp" binding:"required"`
 }
 
 type UserExistsResponse struct {
 	Exists bool         `json:"exists"`
 	User   *models.User `json:"user,omitempty"`
-	Token  string       `json:"token,omitempty"`
+	*TokenPair
 }
 
-// VerifyMobile checks if a user with the given mobile number exists
-// This is the first step in the authentication flow
+// VerifyMobile checks the OTP sent by RequestOTP and whether a user with the
+// given mobile number exists
+// This is the second step in the authentication flow
 func (h *AuthHandler) VerifyMobile(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	var req MobileVerificationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Prove ownership of the mobile number before anything else
+	if !h.checkOTP(c, req.MobileNumber, req.OTP) {
+		return
+	}
+
 	// Check if user with this mobile number already exists
 	var user models.User
 	result := h.db.Where("mobile_number = ?", req.MobileNumber).First(&user)
@@ -42,18 +47,23 @@ func (h *AuthHandler) VerifyMobile(c *gin.Context) {
 			log.Printf("Failed to cache user data: %v", err)
 		}
 
-		// Generate JWT token
-		token, err := GenerateToken(&user)
+		// Generate JWT tokens
+		tokens, err := h.IssueTokens(c.Request.Context(), &user, deviceInfo(c))
 		if err != nil {
 			log.Printf("Failed to generate token: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 			return
 		}
+		metrics.Logins.WithLabelValues("verify_mobile").Inc()
+
+		var urls mediaURLs
+		urls.user(&user)
+		urls.sign(c.Request.Context(), h.db)
 
 		c.JSON(http.StatusOK, UserExistsResponse{
-			Exists: true,
-			User:   &user,
-			Token:  token,
+			Exists:    true,
+			User:      &user,
+			TokenPair: tokens,
 		})
 	} else {
 		// User doesn't exist
@@ -72,21 +82,24 @@ type UserRegistrationRequest struct {
 type SellerDetailsRequest struct {
 	MobileNumber string `json:"mobile_number" binding:"required"`
 	ShopName     string `json:"shop_name" binding:"required"`
-	ProductType  string `json:"product_type" binding:"required"` // "food", "clothes", "beauty", "healthcare"
+	ProductType  string `json:"product_type" binding:"required"` // Category slug, e.g. "food", "clothes", "beauty", "healthcare"
 	ShopUsername string `json:"shop_username" binding:"required"`
+	ShopLocationRequest
 }
 
 // RegisterBasicInfo registers basic user information after mobile verification
-// This is the second step in the registration flow
+// This is the third step in the registration flow
 func (h *AuthHandler) RegisterBasicInfo(c *gin.Context) {
-	<-h.rateLimit.C
-
 	var req UserRegistrationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !requireVerifiedMobile(c, req.MobileNumber) {
+		return
+	}
+
 	var existingUser models.User
 	result := h.db.Where("mobile_number = ?", req.MobileNumber).First(&existingUser)
 	if result.Error == nil {
@@ -104,34 +117,43 @@ func (h *AuthHandler) RegisterBasicInfo(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
 		return
 	}
+	metrics.Registrations.WithLabelValues(user.Role).Inc()
 
 	if err := cache.CacheUser(c.Request.Context(), &user); err != nil {
 		log.Printf("Failed to cache user data: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := GenerateToken(&user)
+	// Generate JWT tokens
+	tokens, err := h.IssueTokens(c.Request.Context(), &user, deviceInfo(c))
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
 
+	var urls mediaURLs
+	urls.user(&user)
+	urls.sign(c.Request.Context(), h.db)
+
 	c.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
 
 func (h *AuthHandler) RegisterSellerDetails(c *gin.Context) {
-	<-h.rateLimit.C
-
 	var req SellerDetailsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !requireVerifiedMobile(c, req.MobileNumber) {
+		return
+	}
+
 	// Fetch the user
 	var user models.User
 	if err := h.db.Where("mobile_number = ?", req.MobileNumber).First(&user).Error; err != nil {
@@ -151,18 +173,31 @@ func (h *AuthHandler) RegisterSellerDetails(c *gin.Context) {
 		return
 	}
 
+	if err := validateShopUsername(h.db, req.ShopUsername, 0); err != nil {
+		c.JSON(shopUsernameErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateProductType(h.db, req.ProductType); err != nil {
+		c.JSON(productTypeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	// Begin transaction
 	tx := h.db.Begin()
 
-	shopID := req.ProductType + req.MobileNumber + req.ShopUsername + req.ShopName
-
+	// ShopID is assigned by models.Shop.BeforeCreate
 	shop := models.Shop{
-		ShopID:       shopID,
 		ShopName:     req.ShopName,
 		ProductType:  req.ProductType,
 		ShopUsername: req.ShopUsername,
 		UserID:       user.ID,
 	}
+	if err := applyShopLocation(&shop, &req.ShopLocationRequest); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := tx.Create(&shop).Error; err != nil {
 		tx.Rollback()
@@ -174,23 +209,38 @@ func (h *AuthHandler) RegisterSellerDetails(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
 		return
 	}
+	metrics.ShopsCreated.Inc()
+
+	// Make the shop findable by location
+	syncShopLocation(c.Request.Context(), &shop)
+
+	// Registration is complete, the verified marker is no longer needed
+	if err := cache.RemoveMobileVerified(c.Request.Context(), req.MobileNumber); err != nil {
+		log.Printf("Failed to remove mobile verification: %v", err)
+	}
 
 	// Optional: refresh user from DB if needed
 	if err := cache.CacheUser(c.Request.Context(), &user); err != nil {
 		log.Printf("Failed to cache user data: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := GenerateToken(&user)
+	// Generate JWT tokens
+	tokens, err := h.IssueTokens(c.Request.Context(), &user, deviceInfo(c))
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
 
+	var urls mediaURLs
+	urls.shop(&shop)
+	urls.sign(c.Request.Context(), h.db)
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Shop registered successfully",
-		"shop":    shop,
-		"token":   token,
+		"message":       "Shop registered successfully",
+		"shop":          shop,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
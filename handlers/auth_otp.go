@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const otpDigits = 6
+
+type OTPRequest struct {
+	MobileNumber string `json:"mobile_number" binding:"required"`
+}
+
+// RequestOTP sends a one-time code to the given mobile number
+// This is the first step in the authentication flow
+func (h *AuthHandler) RequestOTP(c *gin.Context) {
+	var req OTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Enforce the resend cooldown before generating a new code
+	wait, err := cache.AcquireOTPCooldown(ctx, req.MobileNumber)
+	if err != nil {
+		log.Printf("Failed to check OTP cooldown: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
+		return
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds()+0.5)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another OTP"})
+		return
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		log.Printf("Failed to generate OTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
+		return
+	}
+
+	if err := cache.StoreOTP(ctx, req.MobileNumber, h.hashOTP(req.MobileNumber, code)); err != nil {
+		log.Printf("Failed to store OTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
+		return
+	}
+
+	message := fmt.Sprintf("Your Adbiz verification code is %s", code)
+	if err := h.sms.Send(ctx, req.MobileNumber, message); err != nil {
+		log.Printf("Failed to send OTP: %v", err)
+		cache.RemoveOTP(ctx, req.MobileNumber)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send OTP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "OTP sent successfully",
+		"expires_in": int(cache.OTPExpiration.Seconds()),
+	})
+}
+
+// checkOTP verifies a code for the given mobile number and writes the error
+// response itself when verification fails
+func (h *AuthHandler) checkOTP(c *gin.Context, mobileNumber, code string) bool {
+	ctx := c.Request.Context()
+
+	storedHash, err := cache.GetOTPForAttempt(ctx, mobileNumber)
+	switch err {
+	case nil:
+	case cache.ErrOTPNotFound:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired OTP"})
+		return false
+	case cache.ErrOTPTooManyAttempts:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, please request a new OTP"})
+		return false
+	default:
+		log.Printf("Failed to load OTP: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify OTP"})
+		return false
+	}
+
+	if !hmac.Equal([]byte(storedHash), []byte(h.hashOTP(mobileNumber, code))) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired OTP"})
+		return false
+	}
+
+	// A code can only be used once
+	if err := cache.RemoveOTP(ctx, mobileNumber); err != nil {
+		log.Printf("Failed to remove OTP: %v", err)
+	}
+	if err := cache.MarkMobileVerified(ctx, mobileNumber); err != nil {
+		log.Printf("Failed to mark mobile as verified: %v", err)
+	}
+
+	return true
+}
+
+// requireVerifiedMobile ensures ownership of the mobile number was proven
+// through checkOTP recently, and writes the error response otherwise
+func requireVerifiedMobile(c *gin.Context, mobileNumber string) bool {
+	verified, err := cache.IsMobileVerified(c.Request.Context(), mobileNumber)
+	if err != nil {
+		log.Printf("Failed to check mobile verification: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check mobile verification"})
+		return false
+	}
+	if !verified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Mobile number not verified. Please verify with an OTP first."})
+		return false
+	}
+	return true
+}
+
+// generateOTP returns a random numeric code
+func generateOTP() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
+
+// hashOTP binds a code to its mobile number so stored hashes are useless on their own
+func (h *AuthHandler) hashOTP(mobileNumber, code string) string {
+	mac := hmac.New(sha256.New, h.otpSecret)
+	mac.Write([]byte(mobileNumber + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"adbiz_backend/models"
+	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,9 +12,6 @@ import (
 
 // ReactivateUser handles the reactivation of a soft-deleted user account
 func (h *AuthHandler) ReactivateUser(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	// Get mobile number from URL parameter
 	mobileNumber := c.Param("mobile_number")
 	if mobileNumber == "" {
@@ -20,46 +19,26 @@ func (h *AuthHandler) ReactivateUser(c *gin.Context) {
 		return
 	}
 
-	// Begin transaction
-	tx := h.db.Begin()
-
 	// Find the user by mobile number (including soft-deleted users)
 	var user models.User
-	if result := tx.Unscoped().Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		tx.Rollback()
+	if result := h.db.Unscoped().Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	// Check if the account is actually deleted
 	if user.DeletedAt.Time.IsZero() {
-		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Account is already active"})
 		return
 	}
 
-	// Reactivate the user by setting deleted_at to null
-	if err := tx.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user: " + err.Error()})
+	// The owner's sessions were revoked on deletion, so they prove ownership with an OTP
+	if !h.checkReactivationAccess(c, &user) {
 		return
 	}
 
-	// If user is a seller, reactivate their shop as well
-	if user.Role == "seller" {
-		var shop models.Shop
-		if result := tx.Unscoped().Where("user_id = ?", user.ID).First(&shop); result.Error == nil {
-			if err := tx.Unscoped().Model(&shop).Update("deleted_at", nil).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate associated shop: " + err.Error()})
-				return
-			}
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
+	if err := ReactivateUserAccount(c.Request.Context(), h.db, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user: " + err.Error()})
 		return
 	}
 
@@ -68,11 +47,41 @@ func (h *AuthHandler) ReactivateUser(c *gin.Context) {
 	})
 }
 
+// ReactivateUserAccount clears the soft deletion of a user and their shop and makes
+// the shop findable by location again
+func ReactivateUserAccount(ctx context.Context, db *gorm.DB, user *models.User) error {
+	var shop models.Shop
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Reactivate the user by setting deleted_at to null
+		if err := tx.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		// If user is a seller, reactivate their shop as well
+		if user.Role == models.RoleSeller {
+			if result := tx.Unscoped().Where("user_id = ?", user.ID).First(&shop); result.Error == nil {
+				if err := tx.Unscoped().Model(&shop).Update("deleted_at", nil).Error; err != nil {
+					return fmt.Errorf("failed to reactivate associated shop: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+
+	// Make the reactivated shop findable by location again
+	if shop.ID != 0 {
+		shop.DeletedAt = gorm.DeletedAt{}
+		syncShopLocation(ctx, &shop)
+	}
+	return nil
+}
+
 // ReactivateShop handles the reactivation of a soft-deleted shop
 func (h *AuthHandler) ReactivateShop(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	// Get mobile number from URL parameter
 	mobileNumber := c.Param("mobile_number")
 	if mobileNumber == "" {
@@ -90,6 +99,11 @@ func (h *AuthHandler) ReactivateShop(c *gin.Context) {
 		return
 	}
 
+	if !h.checkReactivationAccess(c, &user) {
+		tx.Rollback()
+		return
+	}
+
 	// Check if user is a seller
 	if user.Role != "seller" {
 		tx.Rollback()
@@ -129,7 +143,31 @@ func (h *AuthHandler) ReactivateShop(c *gin.Context) {
 		return
 	}
 
+	// Make the reactivated shop findable by location again
+	shop.DeletedAt = gorm.DeletedAt{}
+	syncShopLocation(c.Request.Context(), &shop)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Shop reactivated successfully",
 	})
 }
+
+// ReactivationRequest carries the OTP used to prove ownership when no token is sent
+type ReactivationRequest struct {
+	OTP string `json:"otp"`
+}
+
+// checkReactivationAccess allows admins and the owner's own token through, and
+// otherwise requires an OTP sent to the account's mobile number
+func (h *AuthHandler) checkReactivationAccess(c *gin.Context, user *models.User) bool {
+	if isOwnerOrAdmin(c, user.ID) {
+		return true
+	}
+
+	var req ReactivationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.OTP == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "OTP is required to reactivate this account"})
+		return false
+	}
+	return h.checkOTP(c, user.MobileNumber, req.OTP)
+}
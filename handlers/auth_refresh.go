@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new token pair.
+// Refresh tokens rotate on every use; presenting an old one revokes the session.
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	sessionID, userID, err := cache.ConsumeRefreshToken(ctx, hashRefreshToken(req.RefreshToken))
+	switch err {
+	case nil:
+	case cache.ErrRefreshTokenReused:
+		// Someone is replaying a rotated token, so neither copy can be trusted
+		if err := revokeSession(ctx, h.db, sessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used"})
+		return
+	case cache.ErrRefreshTokenInvalid:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	default:
+		log.Printf("Failed to consume refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	// The session may have been revoked while the refresh token was still stored
+	if sessionUserID, err := cache.GetSessionUserID(ctx, sessionID); err != nil || sessionUserID != userID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+		return
+	}
+
+	// Load the user again so role changes and deletions take effect
+	var user models.User
+	if result := h.db.First(&user, userID); result.Error != nil {
+		if err := revokeSession(ctx, h.db, sessionID); err != nil {
+			log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	tokens, err := h.issueSessionTokens(ctx, &user, sessionID)
+	if err != nil {
+		log.Printf("Failed to generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	// Keep the stored session in step with the extended Redis lifetime
+	now := time.Now().UTC()
+	if err := h.db.Model(&models.Session{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   now.Add(h.tokens.RefreshTokenTTL),
+	}).Error; err != nil {
+		log.Printf("Failed to update session %s: %v", sessionID, err)
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session of the current access token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if err := revokeSession(ctx, h.db, c.GetString("session_id")); err != nil {
+		log.Printf("Failed to revoke session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	// Block the access token itself in case the session is recreated under the same id
+	if expiresAt, ok := c.Get("token_expires_at"); ok {
+		if err := cache.RevokeJTI(ctx, c.GetString("token_jti"), time.Until(expiresAt.(time.Time))); err != nil {
+			log.Printf("Failed to revoke token: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the authenticated user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := revokeAllSessions(c.Request.Context(), h.db, authUserID.(uint)); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions successfully"})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionResponse is a session as shown to its owner
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the active sessions of the authenticated user
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := authUserID.(uint)
+
+	var sessions []models.Session
+	if result := h.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now().UTC()).
+		Order("created_at DESC").Find(&sessions); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions: " + result.Error.Error()})
+		return
+	}
+
+	// Last-seen times are kept in Redis by AuthMiddleware and are fresher than the database
+	sessionIDs := make([]string, len(sessions))
+	for i, session := range sessions {
+		sessionIDs[i] = session.ID
+	}
+	lastSeen, err := cache.GetSessionsLastSeen(c.Request.Context(), userID, sessionIDs)
+	if err != nil {
+		log.Printf("Failed to read session activity: %v", err)
+	}
+
+	currentSessionID := c.GetString("session_id")
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		if seen, ok := lastSeen[session.ID]; ok && seen.After(session.LastSeenAt) {
+			session.LastSeenAt = seen
+		}
+		response[i] = SessionResponse{
+			Session: session,
+			Current: session.ID == currentSessionID,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": response,
+	})
+}
+
+// DeleteSession revokes one of the authenticated user's sessions
+func (h *AuthHandler) DeleteSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		return
+	}
+
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Only sessions owned by the caller can be revoked
+	var session models.Session
+	if result := h.db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, authUserID.(uint)).First(&session); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := revokeSession(c.Request.Context(), h.db, session.ID); err != nil {
+		log.Printf("Failed to revoke session %s: %v", session.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
+
+// revokeSession ends a single session in Redis and marks it revoked in the database
+func revokeSession(ctx context.Context, db *gorm.DB, sessionID string) error {
+	if err := cache.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// revokeAllSessions ends every session of a user in Redis and the database
+func revokeAllSessions(ctx context.Context, db *gorm.DB, userID uint) error {
+	if err := cache.RevokeAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
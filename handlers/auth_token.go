@@ -1,25 +1,108 @@
 package handlers
 
 import (
+	"adbiz_backend/cache"
 	"adbiz_backend/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// GenerateToken creates a JWT token for the given user or user ID
-func GenerateToken(userOrID interface{}) (string, error) {
-	expiryDays, _ := strconv.Atoi(os.Getenv("JWT_EXPIRY_DAYS"))
-	if expiryDays <= 0 {
-		expiryDays = 7 // Default value
+// TokenPair is returned to clients after a successful login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// DeviceInfo describes the client a session was started from
+type DeviceInfo struct {
+	Name      string
+	UserAgent string
+	IP        string
+}
+
+// deviceInfo reads the client description from the request
+func deviceInfo(c *gin.Context) DeviceInfo {
+	return DeviceInfo{
+		Name:      c.GetHeader("X-Device-Name"),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// IssueTokens starts a new session for the user and returns its first token pair
+func (h *AuthHandler) IssueTokens(ctx context.Context, user *models.User, device DeviceInfo) (*TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	session := models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		DeviceName: device.Name,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(h.tokens.RefreshTokenTTL),
+	}
+	if err := h.db.WithContext(ctx).Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
+	if err := cache.CreateSession(ctx, sessionID, user.ID, h.tokens.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return h.issueSessionTokens(ctx, user, sessionID)
+}
+
+// issueSessionTokens mints an access token and a fresh refresh token for an existing session
+func (h *AuthHandler) issueSessionTokens(ctx context.Context, user *models.User, sessionID string) (*TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if err := cache.StoreRefreshToken(ctx, hashRefreshToken(refreshToken), sessionID, user.ID, h.tokens.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	accessToken, err := h.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.tokens.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// GenerateToken creates a short-lived JWT access token bound to a session
+func (h *AuthHandler) GenerateToken(userOrID interface{}, sessionID string) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"exp": time.Now().Add(time.Hour * 24 * time.Duration(expiryDays)).Unix(),
+		"exp": now.Add(h.tokens.AccessTokenTTL).Unix(),
+		"iat": now.Unix(),
+		"jti": jti,
+		"sid": sessionID,
 	}
 
 	switch v := userOrID.(type) {
@@ -32,29 +115,20 @@ func GenerateToken(userOrID interface{}) (string, error) {
 		return "", fmt.Errorf("invalid argument type for generateToken")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "Tosif@123" // Default value
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
 	}
 
-	return token.SignedString([]byte(secretKey))
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(key.private)
 }
 
 // VerifyToken validates a JWT token and returns the claims if valid
 func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "Tosif@123" // Default value
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.Parse(tokenString, lookupVerificationKey)
 
 	if err != nil {
 		return nil, err
@@ -69,5 +143,28 @@ func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 		return nil, errors.New("invalid token claims")
 	}
 
+	// Tokens issued before sessions existed cannot be revoked, so refuse them
+	if sid, _ := claims["sid"].(string); sid == "" {
+		return nil, errors.New("token has no session")
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, errors.New("token has no id")
+	}
+
 	return claims, nil
 }
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken keeps raw refresh tokens out of Redis
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"adbiz_backend/models"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,46 +10,20 @@ import (
 // Register is a legacy handler for user registration
 // It's kept for backward compatibility
 func (h *AuthHandler) Register(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	// This is a legacy endpoint, redirect to the new registration flow
 	c.JSON(http.StatusOK, gin.H{
-		"message": "This endpoint is deprecated. Please use the new registration flow: /verify-mobile, /register-basic, and /register-seller.",
+		"message": "This endpoint is deprecated. Please use the new registration flow: /request-otp, /verify-mobile, /register-basic, and /register-seller.",
 	})
 }
 
 // GetUser retrieves a user by mobile number
 func (h *AuthHandler) GetUser(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
+	// User resolved and authorized by RequireOwnerOrAdmin
+	user := c.MustGet("subject_user").(*models.User)
 
-	// Retrieve user from database by mobile number
-	var user models.User
-	if result := h.db.Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Check if user is trying to access their own data
-	if user.ID != authUserID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only access your own user data"})
-		return
-	}
+	var urls mediaURLs
+	urls.user(user)
+	urls.sign(c.Request.Context(), h.db)
 
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
@@ -59,49 +32,12 @@ func (h *AuthHandler) GetUser(c *gin.Context) {
 
 // GetShopByUserMobile retrieves a shop by user's mobile number
 func (h *AuthHandler) GetShopByUserMobile(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
-	fmt.Println("first")
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-	fmt.Println("first2")
+	// Shop resolved and authorized by RequireOwnerOrAdmin and LoadSubjectShop
+	shop := c.MustGet("subject_shop").(*models.Shop)
 
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	fmt.Println("first3")
-
-	// First, find the user by mobile number
-	var user models.User
-	if result := h.db.Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-	fmt.Println("you are here", user)
-
-	// Check if user is trying to access their own data
-	if user.ID != authUserID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only access your own shop data"})
-		return
-	}
-
-	fmt.Println("now here")
-
-	// Now, find the shop associated with this user
-	var shop models.Shop
-	if result := h.db.Where("user_id = ?", user.ID).First(&shop); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found for this user"})
-		return
-	}
-	fmt.Println("this is your shop", shop)
+	var urls mediaURLs
+	urls.shop(shop)
+	urls.sign(c.Request.Context(), h.db)
 
 	c.JSON(http.StatusOK, gin.H{
 		"shop": shop,
@@ -112,42 +48,15 @@ func (h *AuthHandler) GetShopByUserMobile(c *gin.Context) {
 type UpdateUserRequest struct {
 	Name         string  `json:"name"`
 	Email        *string `json:"email,omitempty"`
-	ProfilePhoto *string `json:"profile_photo,omitempty"`
+	ProfilePhoto *string `json:"profile_photo,omitempty"` // Media ID, or "" to remove the photo
 	MobileNumber string  `json:"mobile_number"`
-	Role         string  `json:"role"`
+	Role         string  `json:"role" binding:"omitempty,oneof=buyer seller admin"`
 }
 
 // UpdateUser updates a user's information
 func (h *AuthHandler) UpdateUser(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-
-	// First, find the user by mobile number
-	var user models.User
-	if result := h.db.Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Check if user is trying to update their own data
-	if user.ID != authUserID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own user data"})
-		return
-	}
+	// User resolved and authorized by RequireOwnerOrAdmin
+	user := c.MustGet("subject_user").(*models.User)
 
 	// Parse request body
 	var req UpdateUserRequest
@@ -164,21 +73,38 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		user.Email = req.Email
 	}
 	if req.ProfilePhoto != nil {
-		user.ProfilePhoto = req.ProfilePhoto
+		if *req.ProfilePhoto == "" {
+			user.ProfilePhoto = nil
+		} else {
+			if err := validateOwnedMedia(h.db, user.ID, *req.ProfilePhoto); err != nil {
+				c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			user.ProfilePhoto = req.ProfilePhoto
+		}
 	}
 	if req.MobileNumber != "" {
 		user.MobileNumber = req.MobileNumber
 	}
-	if req.Role != "" {
+	if req.Role != "" && req.Role != user.Role {
+		// Admin rights can only be granted or removed by another admin
+		if (req.Role == models.RoleAdmin || user.Role == models.RoleAdmin) && c.GetString("role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change admin roles"})
+			return
+		}
 		user.Role = req.Role
 	}
 
 	// Save updated user to database
-	if err := h.db.Save(&user).Error; err != nil {
+	if err := h.db.Save(user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user: " + err.Error()})
 		return
 	}
 
+	var urls mediaURLs
+	urls.user(user)
+	urls.sign(c.Request.Context(), h.db)
+
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
 	})
@@ -187,43 +113,17 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 type UpdateShopRequest struct {
 	Bio          *string `json:"bio,omitempty"`
 	Location     *string `json:"location,omitempty"`
-	ShopPhoto    *string `json:"shop_photo,omitempty"`
+	ShopPhoto    *string `json:"shop_photo,omitempty"` // Media ID, or "" to remove the photo
 	ShopUsername string  `json:"shop_username"`
 	ShopName     string  `json:"shop_name"`
 	ProductType  string  `json:"product_type"`
+	ShopLocationRequest
 }
 
 // UpdateUser updates a user's information
 func (h *AuthHandler) UpdateShop(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
-	// Get mobile number from URL parameter
-	mobileNumber := c.Param("mobile_number")
-	if mobileNumber == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
-		return
-	}
-
-	// First, find the user by mobile number
-	var user models.User
-	if result := h.db.Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	// Get authenticated user ID from context
-	authUserID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Check if user is trying to update their own data
-	if user.ID != authUserID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own shop data"})
-		return
-	}
+	// Shop resolved and authorized by RequireOwnerOrAdmin and LoadSubjectShop
+	shop := c.MustGet("subject_shop").(*models.Shop)
 
 	// Parse request body
 	var req UpdateShopRequest
@@ -232,13 +132,6 @@ func (h *AuthHandler) UpdateShop(c *gin.Context) {
 		return
 	}
 
-	// Now, find the shop associated with this user
-	var shop models.Shop
-	if result := h.db.Where("user_id = ?", user.ID).First(&shop); result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found for this user"})
-		return
-	}
-
 	if req.Bio != nil {
 		shop.Bio = req.Bio
 	}
@@ -246,23 +139,49 @@ func (h *AuthHandler) UpdateShop(c *gin.Context) {
 		shop.Location = req.Location
 	}
 	if req.ShopPhoto != nil {
-		shop.ShopPhoto = req.ShopPhoto
-	}
-	if req.ShopUsername != "" {
+		if *req.ShopPhoto == "" {
+			shop.ShopPhoto = nil
+		} else {
+			if err := validateOwnedMedia(h.db, shop.UserID, *req.ShopPhoto); err != nil {
+				c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			shop.ShopPhoto = req.ShopPhoto
+		}
+	}
+	if req.ShopUsername != "" && req.ShopUsername != shop.ShopUsername {
+		if err := validateShopUsername(h.db, req.ShopUsername, shop.ID); err != nil {
+			c.JSON(shopUsernameErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
 		shop.ShopUsername = req.ShopUsername
 	}
 	if req.ShopName != "" {
 		shop.ShopName = req.ShopName
 	}
-	if req.ProductType != "" {
+	if req.ProductType != "" && req.ProductType != shop.ProductType {
+		if err := validateProductType(h.db, req.ProductType); err != nil {
+			c.JSON(productTypeErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
 		shop.ProductType = req.ProductType
 	}
 
+	if err := applyShopLocation(shop, &req.ShopLocationRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Save updated shop to database
-	if err := h.db.Save(&shop).Error; err != nil {
+	if err := h.db.Save(shop).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shop: " + err.Error()})
 		return
 	}
+	syncShopLocation(c.Request.Context(), shop)
+
+	var urls mediaURLs
+	urls.shop(shop)
+	urls.sign(c.Request.Context(), h.db)
 
 	c.JSON(http.StatusOK, gin.H{
 		"user": shop,
@@ -270,10 +189,8 @@ func (h *AuthHandler) UpdateShop(c *gin.Context) {
 }
 
 // GetAllUser retrieves all users in the database
+// Only admins can reach this route
 func (h *AuthHandler) GetAllUsers(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	// Get authenticated user ID from context
 	_, exists := c.Get("user_id")
 	if !exists {
@@ -288,6 +205,12 @@ func (h *AuthHandler) GetAllUsers(c *gin.Context) {
 		return
 	}
 
+	var urls mediaURLs
+	for i := range users {
+		urls.user(&users[i])
+	}
+	urls.sign(c.Request.Context(), h.db)
+
 	c.JSON(http.StatusOK, gin.H{
 		"users": users,
 	})
@@ -298,10 +221,8 @@ type ListOfUsersMobileNumber struct {
 }
 
 // GetAllUserInfo from list of mobile numbers
+// Deprecated: clients should page through /users/:id/following instead
 func (h *AuthHandler) GetAllFavUsersInfo(c *gin.Context) {
-	// Apply rate limiting
-	<-h.rateLimit.C
-
 	var req ListOfUsersMobileNumber
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -322,6 +243,12 @@ func (h *AuthHandler) GetAllFavUsersInfo(c *gin.Context) {
 		return
 	}
 
+	var urls mediaURLs
+	for i := range users {
+		urls.user(&users[i])
+	}
+	urls.sign(c.Request.Context(), h.db)
+
 	c.JSON(http.StatusOK, gin.H{
 		"users": users,
 	})
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryHandler struct {
+	db *gorm.DB
+}
+
+func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
+	return &CategoryHandler{
+		db: db,
+	}
+}
+
+var errUnknownProductType = errors.New("product_type must be the slug of an existing category")
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validateProductType checks that productType is the slug of a category
+func validateProductType(db *gorm.DB, productType string) error {
+	var count int64
+	if err := db.Model(&models.Category{}).Where("slug = ?", productType).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errUnknownProductType
+	}
+	return nil
+}
+
+// productTypeErrorStatus maps a validateProductType error to an HTTP status
+func productTypeErrorStatus(err error) int {
+	if err == errUnknownProductType {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// categorySubtreeSlugs returns the slug of the category and of all its descendants,
+// or nil when the category does not exist
+func categorySubtreeSlugs(db *gorm.DB, slug string) ([]string, error) {
+	var slugs []string
+	err := db.Raw(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, slug FROM categories WHERE slug = ?
+			UNION ALL
+			SELECT c.id, c.slug FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT slug FROM subtree`, slug).Scan(&slugs).Error
+	return slugs, err
+}
+
+// requestLanguage picks the language for category names from ?lang= or the
+// first Accept-Language tag
+func requestLanguage(c *gin.Context) string {
+	if lang := c.Query("lang"); lang != "" {
+		return strings.ToLower(lang)
+	}
+	tag, _, _ := strings.Cut(c.GetHeader("Accept-Language"), ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	if tag == "" || tag == "*" {
+		return "en"
+	}
+	return strings.ToLower(tag)
+}
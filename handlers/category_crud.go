@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateCategoryRequest defines the request structure for creating a category
+type CreateCategoryRequest struct {
+	Slug     string            `json:"slug" binding:"required,max=50"`
+	ParentID *uint             `json:"parent_id,omitempty"`
+	Names    map[string]string `json:"names" binding:"required,dive,keys,min=2,max=10,endkeys,required,max=100"`
+	Icon     *string           `json:"icon,omitempty" binding:"omitempty,max=500"`
+	Position int               `json:"position"`
+}
+
+// UpdateCategoryRequest defines the request structure for updating a category.
+// Slugs are stored on shops and posts, so they cannot be changed.
+type UpdateCategoryRequest struct {
+	ParentID *uint             `json:"parent_id,omitempty"` // 0 moves the category to the top level
+	Names    map[string]string `json:"names,omitempty" binding:"omitempty,dive,keys,min=2,max=10,endkeys,required,max=100"`
+	Icon     *string           `json:"icon,omitempty" binding:"omitempty,max=500"`
+	Position *int              `json:"position,omitempty"`
+}
+
+// CategoryNode is a category with its name in the requested language and its children
+type CategoryNode struct {
+	models.Category
+	Name     string          `json:"name"`
+	Children []*CategoryNode `json:"children"`
+}
+
+// ListCategories returns the category tree
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := h.db.Order("position, slug").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories: " + err.Error()})
+		return
+	}
+
+	lang := requestLanguage(c)
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			Category: category,
+			Name:     category.DisplayName(lang),
+			Children: []*CategoryNode{},
+		}
+	}
+
+	// categories is ordered, so appending keeps siblings ordered too
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": roots,
+	})
+}
+
+// CreateCategory adds a category to the taxonomy
+// Only admins can reach this route
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !categorySlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug must be lowercase letters and digits separated by '-'"})
+		return
+	}
+	if req.Names["en"] == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "names must include an English (\"en\") name"})
+		return
+	}
+
+	category := models.Category{
+		Slug:     req.Slug,
+		Names:    req.Names,
+		Icon:     req.Icon,
+		Position: req.Position,
+	}
+	if req.ParentID != nil && *req.ParentID != 0 {
+		if !h.categoryExists(c, *req.ParentID) {
+			return
+		}
+		category.ParentID = req.ParentID
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Category{}).Where("slug = ?", req.Slug).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check slug: " + err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category slug already exists"})
+		return
+	}
+
+	if err := h.db.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"category": category,
+	})
+}
+
+// UpdateCategory changes the names, icon, position or parent of a category
+// Only admins can reach this route
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	var req UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, ok := h.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	if req.Names != nil {
+		if req.Names["en"] == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "names must include an English (\"en\") name"})
+			return
+		}
+		category.Names = req.Names
+	}
+	if req.Icon != nil {
+		category.Icon = req.Icon
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if !h.categoryExists(c, *req.ParentID) {
+				return
+			}
+
+			// The new parent must not be the category itself or one of its descendants
+			var descendant int64
+			if err := h.db.Raw(`
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM categories WHERE id = ?
+					UNION ALL
+					SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+				)
+				SELECT COUNT(*) FROM subtree WHERE id = ?`, category.ID, *req.ParentID).
+				Scan(&descendant).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check parent: " + err.Error()})
+				return
+			}
+			if descendant > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A category cannot be moved under itself or its descendants"})
+				return
+			}
+			category.ParentID = req.ParentID
+		}
+	}
+
+	if err := h.db.Save(category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"category": category,
+	})
+}
+
+// DeleteCategory removes a category that has no children and is not used by any shop or post
+// Only admins can reach this route
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	category, ok := h.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	var children, shops, posts int64
+	if err := h.db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check category: " + err.Error()})
+		return
+	}
+	// Soft-deleted shops and posts count too, since they can be reactivated
+	if err := h.db.Unscoped().Model(&models.Shop{}).Where("product_type = ?", category.Slug).Count(&shops).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check category: " + err.Error()})
+		return
+	}
+	if err := h.db.Unscoped().Model(&models.Post{}).Where("product_type = ?", category.Slug).Count(&posts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check category: " + err.Error()})
+		return
+	}
+	if children > 0 || shops > 0 || posts > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories or is used by shops or posts"})
+		return
+	}
+
+	if err := h.db.Delete(category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Category deleted successfully",
+	})
+}
+
+// loadCategory reads the category named by the :slug path parameter
+func (h *CategoryHandler) loadCategory(c *gin.Context) (*models.Category, bool) {
+	var category models.Category
+	if result := h.db.Where("slug = ?", c.Param("slug")).First(&category); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find category: " + result.Error.Error()})
+		}
+		return nil, false
+	}
+	return &category, true
+}
+
+// categoryExists reports whether the category with the given ID exists, writing a 400
+// response when it does not
+func (h *CategoryHandler) categoryExists(c *gin.Context, id uint) bool {
+	var count int64
+	if err := h.db.Model(&models.Category{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check parent: " + err.Error()})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCategoryShops lists the shops in a category or any of its subcategories, newest first
+func (h *CategoryHandler) GetCategoryShops(c *gin.Context) {
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	slugs, err := categorySubtreeSlugs(h.db, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find category: " + err.Error()})
+		return
+	}
+	if len(slugs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	query := h.db.Model(&models.Shop{}).
+		Joins("JOIN users ON users.id = shops.user_id AND users.deleted_at IS NULL").
+		Where("shops.product_type IN ?", slugs)
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		createdAt, shopID, err := decodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("(shops.created_at, shops.id) < (?, ?)", time.Unix(0, createdAt).UTC(), shopID)
+	}
+
+	var shops []models.Shop
+	if err := query.Order("shops.created_at DESC, shops.id DESC").Limit(limit + 1).Find(&shops).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shops: " + err.Error()})
+		return
+	}
+
+	// The extra row only tells us whether another page exists
+	nextCursor := ""
+	if len(shops) > limit {
+		shops = shops[:limit]
+		last := shops[len(shops)-1]
+		nextCursor = encodeCursor(last.CreatedAt.UnixNano(), last.ID)
+	}
+
+	var urls mediaURLs
+	summaries := make([]ShopSummary, len(shops))
+	for i, shop := range shops {
+		summaries[i] = newShopSummary(&shop)
+		urls.shopSummary(&summaries[i])
+	}
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"shops":       summaries,
+		"next_cursor": nextCursor,
+	})
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"adbiz_backend/analytics"
+	"adbiz_backend/ids"
+	"adbiz_backend/metrics"
+	"adbiz_backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ShopSummary is the public part of a shop shown in user lists
+type ShopSummary struct {
+	ShopID       string  `json:"shop_id"`
+	ShopName     string  `json:"shop_name"`
+	ShopUsername string  `json:"shop_username"`
+	ProductType  string  `json:"product_type"`
+	ShopPhoto    *string `json:"shop_photo,omitempty"`
+	ShopPhotoURL *string `json:"shop_photo_url,omitempty"`
+}
+
+// FollowUserSummary is a user in a follower or following list
+type FollowUserSummary struct {
+	ID              uint         `json:"-"`
+	PublicID        string       `json:"public_id"`
+	Name            string       `json:"name"`
+	Role            string       `json:"role"`
+	ProfilePhoto    *string      `json:"profile_photo,omitempty"`
+	ProfilePhotoURL *string      `json:"profile_photo_url,omitempty"`
+	Shop            *ShopSummary `json:"shop,omitempty"`
+	FollowedAt      time.Time    `json:"followed_at"`
+	IsFollowing     bool         `json:"is_following"` // Whether the caller follows this user
+}
+
+type UnfavRequest struct {
+	TargetUserMobile string `json:"target_user_mobile" binding:"required"`
+}
+
+// HandleUnfav removes the follow edge from the authenticated user to the target user
+func (h *FavHandler) HandleUnfav(c *gin.Context) {
+	var req UnfavRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Soft-deleted users can still be unfollowed
+	var targetUser models.User
+	if err := h.db.Unscoped().Where("mobile_number = ?", req.TargetUserMobile).First(&targetUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target user not found"})
+		return
+	}
+
+	result := h.db.Where("follower_id = ? AND followee_id = ?", authUserID.(uint), targetUser.ID).
+		Delete(&models.Follow{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update following: " + result.Error.Error()})
+		return
+	}
+	if result.RowsAffected > 0 {
+		metrics.Unfollows.Inc()
+		go trackFollow(h.db, targetUser.ID, analytics.Unfollow)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Favorite removed successfully"})
+}
+
+// GetFollowers lists the users following :id, newest first
+func (h *FavHandler) GetFollowers(c *gin.Context) {
+	h.listFollows(c, "followee_id", "follower_id")
+}
+
+// GetFollowing lists the users :id follows, newest first
+func (h *FavHandler) GetFollowing(c *gin.Context) {
+	h.listFollows(c, "follower_id", "followee_id")
+}
+
+// listFollows pages through follow edges where subjectColumn is the path user,
+// returning the users found in otherColumn
+func (h *FavHandler) listFollows(c *gin.Context, subjectColumn, otherColumn string) {
+	// :id is the public ID; numeric IDs are not accepted so that users cannot be enumerated
+	publicID := c.Param("id")
+	if !ids.IsValid(publicID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	var subject models.User
+	if err := h.db.Where("public_id = ?", publicID).First(&subject).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	query := h.db.Table("follows").
+		Select("users.*, follows.created_at AS followed_at").
+		Joins("JOIN users ON users.id = follows."+otherColumn+" AND users.deleted_at IS NULL").
+		Where("follows."+subjectColumn+" = ?", subject.ID)
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		followedAt, otherID, err := decodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("(follows.created_at, follows."+otherColumn+") < (?, ?)", time.Unix(0, followedAt).UTC(), otherID)
+	}
+
+	var rows []struct {
+		models.User
+		FollowedAt time.Time
+	}
+	if err := query.Order("follows.created_at DESC, follows." + otherColumn + " DESC").
+		Limit(limit + 1).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows: " + err.Error()})
+		return
+	}
+
+	// The extra row only tells us whether another page exists
+	nextCursor := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		nextCursor = encodeCursor(last.FollowedAt.UnixNano(), last.ID)
+	}
+
+	userIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		userIDs[i] = row.ID
+	}
+
+	shops, err := loadShopSummaries(h.db, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shops: " + err.Error()})
+		return
+	}
+
+	following, err := loadFollowingSet(h.db, authUserID.(uint), userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows: " + err.Error()})
+		return
+	}
+
+	users := make([]FollowUserSummary, len(rows))
+	for i, row := range rows {
+		users[i] = FollowUserSummary{
+			ID:           row.ID,
+			PublicID:     row.PublicID,
+			Name:         row.Name,
+			Role:         row.Role,
+			ProfilePhoto: row.ProfilePhoto,
+			Shop:         shops[row.ID],
+			FollowedAt:   row.FollowedAt,
+			IsFollowing:  following[row.ID],
+		}
+	}
+
+	var urls mediaURLs
+	for i := range users {
+		user := &users[i]
+		urls.add(user.ProfilePhoto, avatarVariant, func(url string) { user.ProfilePhotoURL = &url })
+		if user.Shop != nil {
+			urls.shopSummary(user.Shop)
+		}
+	}
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":       users,
+		"next_cursor": nextCursor,
+	})
+}
+
+// newShopSummary returns the public part of a shop
+func newShopSummary(shop *models.Shop) ShopSummary {
+	return ShopSummary{
+		ShopID:       shop.ShopID,
+		ShopName:     shop.ShopName,
+		ShopUsername: shop.ShopUsername,
+		ProductType:  shop.ProductType,
+		ShopPhoto:    shop.ShopPhoto,
+	}
+}
+
+// loadShopSummaries loads the active shops of the given users, keyed by user ID
+func loadShopSummaries(db *gorm.DB, userIDs []uint) (map[uint]*ShopSummary, error) {
+	summaries := make(map[uint]*ShopSummary, len(userIDs))
+	if len(userIDs) == 0 {
+		return summaries, nil
+	}
+
+	var shops []models.Shop
+	if err := db.Where("user_id IN ?", userIDs).Find(&shops).Error; err != nil {
+		return nil, err
+	}
+
+	for _, shop := range shops {
+		summary := newShopSummary(&shop)
+		summaries[shop.UserID] = &summary
+	}
+	return summaries, nil
+}
+
+// loadFollowingSet reports which of the given users followerID follows
+func loadFollowingSet(db *gorm.DB, followerID uint, userIDs []uint) (map[uint]bool, error) {
+	following := make(map[uint]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return following, nil
+	}
+
+	var followeeIDs []uint
+	if err := db.Model(&models.Follow{}).
+		Where("follower_id = ? AND followee_id IN ?", followerID, userIDs).
+		Pluck("followee_id", &followeeIDs).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range followeeIDs {
+		following[id] = true
+	}
+	return following, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FavList is the legacy following list of a user, in the shape of the dropped fav1 table
+type FavList struct {
+	models.Model
+	Fav     int      `json:"fav"`
+	FavList []string `json:"favlist"` // List of mobile numbers
+	UserID  uint     `json:"-"`
+}
+
+// followingFavList builds the legacy following list of a user from the Follow table.
+// It returns false when the user does not follow anyone.
+func followingFavList(db *gorm.DB, userID uint) (FavList, bool, error) {
+	var rows []struct {
+		MobileNumber string
+		CreatedAt    time.Time
+	}
+	err := db.Table("follows").
+		Select("users.mobile_number, follows.created_at").
+		Joins("JOIN users ON users.id = follows.followee_id AND users.deleted_at IS NULL").
+		Where("follows.follower_id = ?", userID).
+		Order("follows.created_at, follows.followee_id").
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return FavList{}, false, err
+	}
+
+	fav := FavList{
+		Fav:     len(rows),
+		FavList: make([]string, len(rows)),
+		UserID:  userID,
+	}
+	fav.CreatedAt = rows[0].CreatedAt
+	fav.UpdatedAt = rows[len(rows)-1].CreatedAt
+	for i, row := range rows {
+		fav.FavList[i] = row.MobileNumber
+	}
+	return fav, true, nil
+}
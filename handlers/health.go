@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/config"
+	"adbiz_backend/migrations"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// HealthHandler answers the liveness and readiness probes of orchestrators and load balancers
+type HealthHandler struct {
+	db           *gorm.DB
+	checkTimeout time.Duration
+	draining     atomic.Bool
+}
+
+func NewHealthHandler(db *gorm.DB, cfg *config.Config) *HealthHandler {
+	return &HealthHandler{
+		db:           db,
+		checkTimeout: cfg.Server.HealthCheckTimeout,
+	}
+}
+
+// CheckResult is the outcome of one readiness check. The reason of a failure is only
+// logged, so the unauthenticated probe does not reveal hosts or driver errors.
+type CheckResult struct {
+	Status     string `json:"status"` // "ok" or "failed"
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// StartDraining makes /readyz report not ready from now on, ahead of a graceful shutdown
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// Liveness reports that the process is up and serving requests. It checks no
+// dependencies, so an outage of Postgres or Redis does not get the process restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness reports whether the instance should receive traffic: Postgres and Redis
+// answer and the schema is migrated. Each check has its own timeout and they run in parallel.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"postgres":   h.checkPostgres,
+		"redis":      cache.Ping,
+		"migrations": h.checkMigrations,
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]CheckResult, len(checks))
+		ready   = true
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), h.checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			result := CheckResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "failed"
+				log.Printf("Readiness check %s failed: %v", name, err)
+			}
+
+			mu.Lock()
+			results[name] = result
+			ready = ready && err == nil
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "checks": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": results})
+}
+
+func (h *HealthHandler) checkPostgres(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+	pending, err := migrations.CountPending(ctx, h.db)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending", pending)
+	}
+	return nil
+}
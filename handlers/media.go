@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"adbiz_backend/config"
+	"adbiz_backend/models"
+	"adbiz_backend/storage"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type MediaHandler struct {
+	db             *gorm.DB
+	store          storage.BlobStore
+	maxUploadBytes int64 // Largest image accepted by UploadMedia
+}
+
+func NewMediaHandler(db *gorm.DB, store storage.BlobStore, cfg *config.Config) *MediaHandler {
+	return &MediaHandler{
+		db:             db,
+		store:          store,
+		maxUploadBytes: int64(cfg.Media.MaxUploadMB) << 20,
+	}
+}
+
+var errMediaNotOwned = errors.New("media must be IDs of images uploaded by the account owner")
+
+// validateOwnedMedia checks that every media ID was uploaded by userID
+func validateOwnedMedia(db *gorm.DB, userID uint, mediaIDs ...string) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	unique := make(map[string]bool, len(mediaIDs))
+	for _, id := range mediaIDs {
+		unique[id] = true
+	}
+
+	var count int64
+	if err := db.Model(&models.Media{}).Where("id IN ? AND user_id = ?", mediaIDs, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return errMediaNotOwned
+	}
+	return nil
+}
+
+// mediaErrorStatus maps a validateOwnedMedia error to an HTTP status
+func mediaErrorStatus(err error) int {
+	if err == errMediaNotOwned {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"adbiz_backend/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeLocalMedia serves files of the local BlobStore at the URLs made by its
+// SignedURL. It checks the signature, answers range and conditional requests,
+// and lets clients cache the file until the URL expires.
+func (h *MediaHandler) ServeLocalMedia(c *gin.Context) {
+	local, ok := h.store.(*storage.LocalStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expiresAt, err := local.Verify(key, c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := local.Open(key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		} else {
+			log.Printf("Failed to open media %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		return
+	}
+
+	// Keys are never reused, so the file behind a URL does not change while it is valid
+	maxAge := int(time.Until(expiresAt).Seconds())
+	sum := sha256.Sum256([]byte(key))
+
+	header := c.Writer.Header()
+	header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d, immutable", maxAge))
+	header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	// ServeContent handles Range, If-Range, If-None-Match and If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime(), file)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"adbiz_backend/ids"
+	"adbiz_backend/media"
+	"adbiz_backend/models"
+	"adbiz_backend/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// processSlots bounds how many uploads are decoded and resized at once
+var processSlots = make(chan struct{}, runtime.NumCPU())
+
+// MediaResponse is an uploaded image with the signed download URL of each variant
+type MediaResponse struct {
+	models.Media
+	URLs map[string]string `json:"urls"`
+}
+
+// UploadMedia accepts a multipart image in the "file" field, strips its metadata,
+// renders thumbnails and stores them. The returned ID can then be used as a profile,
+// shop or post photo by the uploader.
+func (h *MediaHandler) UploadMedia(c *gin.Context) {
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Leave room for the multipart headers around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadBytes+64<<10)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must be at most %d MB", h.maxUploadBytes>>20)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		}
+		return
+	}
+	defer file.Close()
+
+	if header.Size > h.maxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must be at most %d MB", h.maxUploadBytes>>20)})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, h.maxUploadBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+	if int64(len(data)) > h.maxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file must be at most %d MB", h.maxUploadBytes>>20)})
+		return
+	}
+
+	processSlots <- struct{}{}
+	images, err := media.Process(data)
+	<-processSlots
+	if err != nil {
+		switch err {
+		case media.ErrUnsupportedType:
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case media.ErrTooLarge:
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image: " + err.Error()})
+		}
+		return
+	}
+
+	ctx := c.Request.Context()
+	original := images[0]
+	record := models.Media{
+		ID:          ids.New(),
+		UserID:      authUserID.(uint),
+		ContentType: original.ContentType,
+		Size:        int64(len(original.Data)),
+		Width:       original.Width,
+		Height:      original.Height,
+		Keys:        make(map[string]string, len(images)),
+	}
+	for _, image := range images {
+		key := fmt.Sprintf("%s/%s.%s", record.ID, image.Name, image.Extension)
+		if err := h.store.Put(ctx, key, bytes.NewReader(image.Data), int64(len(image.Data)), image.ContentType); err != nil {
+			h.deleteBlobs(ctx, record.Keys)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image: " + err.Error()})
+			return
+		}
+		record.Keys[image.Name] = key
+	}
+
+	if err := h.db.Create(&record).Error; err != nil {
+		h.deleteBlobs(ctx, record.Keys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save media: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"media": h.mediaResponse(ctx, &record),
+	})
+}
+
+// GetMedia redirects to the stored image of a media ID, in the variant named by
+// ?size= ("original" by default)
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	var record models.Media
+	if result := h.db.Where("id = ?", c.Param("id")).First(&record); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find media: " + result.Error.Error()})
+		}
+		return
+	}
+
+	size := c.DefaultQuery("size", "original")
+	key, ok := record.Keys[size]
+	if !ok {
+		known := size == "original"
+		for _, variant := range media.Variants {
+			known = known || variant.Name == size
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown size " + size})
+			return
+		}
+		// Images smaller than a variant are not resized, so the original stands in for it
+		key = record.Keys["original"]
+	}
+
+	url, err := h.store.SignedURL(c.Request.Context(), key, storage.URLExpiry(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign media URL: " + err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// mediaResponse adds the signed download URLs to a media record
+func (h *MediaHandler) mediaResponse(ctx context.Context, record *models.Media) MediaResponse {
+	expires := storage.URLExpiry(time.Now())
+	urls := make(map[string]string, len(record.Keys))
+	for name, key := range record.Keys {
+		url, err := h.store.SignedURL(ctx, key, expires)
+		if err != nil {
+			log.Printf("Failed to sign media URL: %v", err)
+			continue
+		}
+		urls[name] = url
+	}
+	return MediaResponse{Media: *record, URLs: urls}
+}
+
+// deleteBlobs removes the blobs of an upload that could not be completed
+func (h *MediaHandler) deleteBlobs(ctx context.Context, keys map[string]string) {
+	for _, key := range keys {
+		if err := h.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"adbiz_backend/ids"
+	"adbiz_backend/models"
+	"adbiz_backend/storage"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Variants used for the photos embedded in responses; other sizes are at /media/:id
+const (
+	avatarVariant    = "medium"
+	postMediaVariant = "large"
+)
+
+// mediaURLs collects the media IDs referenced by a response and fills in signed,
+// expiring download URLs for them, so stored paths never appear in responses
+type mediaURLs struct {
+	refs []mediaRef
+}
+
+type mediaRef struct {
+	id      string
+	variant string
+	set     func(url string)
+}
+
+func (m *mediaURLs) add(id *string, variant string, set func(url string)) {
+	if id != nil && *id != "" {
+		m.refs = append(m.refs, mediaRef{id: *id, variant: variant, set: set})
+	}
+}
+
+func (m *mediaURLs) user(u *models.User) {
+	m.add(u.ProfilePhoto, avatarVariant, func(url string) { u.ProfilePhotoURL = &url })
+}
+
+func (m *mediaURLs) shop(s *models.Shop) {
+	m.add(s.ShopPhoto, avatarVariant, func(url string) { s.ShopPhotoURL = &url })
+}
+
+func (m *mediaURLs) shopSummary(s *ShopSummary) {
+	m.add(s.ShopPhoto, avatarVariant, func(url string) { s.ShopPhotoURL = &url })
+}
+
+// post fills MediaURLs in the order of Media, leaving "" for media that is gone
+func (m *mediaURLs) post(p *models.Post) {
+	p.MediaURLs = make([]string, len(p.Media))
+	for i := range p.Media {
+		i := i
+		m.add(&p.Media[i], postMediaVariant, func(url string) { p.MediaURLs[i] = url })
+	}
+}
+
+// sign looks up the collected media and sets their URLs. Values that are not media
+// IDs are URLs saved before uploads existed and are passed through as they are.
+// Failures are logged, leaving the URLs unset.
+func (m *mediaURLs) sign(ctx context.Context, db *gorm.DB) {
+	if len(m.refs) == 0 || storage.Blobs == nil {
+		return
+	}
+
+	var mediaIDs []string
+	for _, ref := range m.refs {
+		if ids.IsValid(ref.id) {
+			mediaIDs = append(mediaIDs, ref.id)
+		} else if strings.HasPrefix(ref.id, "https://") || strings.HasPrefix(ref.id, "http://") {
+			ref.set(ref.id)
+		}
+	}
+	if len(mediaIDs) == 0 {
+		return
+	}
+
+	var records []models.Media
+	if err := db.Where("id IN ?", mediaIDs).Find(&records).Error; err != nil {
+		log.Printf("Failed to load media: %v", err)
+		return
+	}
+	keys := make(map[string]map[string]string, len(records))
+	for _, record := range records {
+		keys[record.ID] = record.Keys
+	}
+
+	expires := storage.URLExpiry(time.Now())
+	for _, ref := range m.refs {
+		variants, ok := keys[ref.id]
+		if !ok {
+			continue
+		}
+		key, ok := variants[ref.variant]
+		if !ok {
+			key = variants["original"] // Smaller than the variant, so never resized
+		}
+		url, err := storage.Blobs.SignedURL(ctx, key, expires)
+		if err != nil {
+			log.Printf("Failed to sign media URL: %v", err)
+			continue
+		}
+		ref.set(url)
+	}
+}
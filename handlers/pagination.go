@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"adbiz_backend/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageLimit reads the limit query parameter and writes the error response when it is invalid
+func pageLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultPageSize, true
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		return 0, false
+	}
+	return limit, true
+}
+
+// cursorAEAD encrypts pagination cursors so the row IDs in them stay hidden and
+// cannot be altered
+var cursorAEAD cipher.AEAD
+
+// LoadCursorKey derives the cursor encryption key from the configured secret.
+// In development a temporary key is generated when none is configured.
+func LoadCursorKey(cfg *config.Config) error {
+	var key [sha256.Size]byte
+	if cfg.Server.CursorSecret != "" {
+		key = sha256.Sum256([]byte(cfg.Server.CursorSecret))
+	} else {
+		if !cfg.Development() {
+			return errors.New("CURSOR_SECRET must be set outside development")
+		}
+		if _, err := rand.Read(key[:]); err != nil {
+			return fmt.Errorf("failed to generate cursor key: %w", err)
+		}
+		log.Printf("Warning: CURSOR_SECRET not set, using a temporary key (cursors will not survive a restart)")
+	}
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	cursorAEAD = aead
+	return nil
+}
+
+// sealCursor encrypts a cursor payload into an opaque string
+func sealCursor(raw string) string {
+	nonce := make([]byte, cursorAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		log.Fatalf("Failed to generate cursor nonce: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorAEAD.Seal(nonce, nonce, []byte(raw), nil))
+}
+
+// openCursor reverses sealCursor, rejecting cursors that were not issued by us
+func openCursor(cursor string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < cursorAEAD.NonceSize() {
+		return "", fmt.Errorf("malformed cursor")
+	}
+	nonce, ciphertext := sealed[:cursorAEAD.NonceSize()], sealed[cursorAEAD.NonceSize():]
+	raw, err := cursorAEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("malformed cursor")
+	}
+	return string(raw), nil
+}
+
+// encodeCursor packs a sort position and a tie-breaking ID into an opaque string
+func encodeCursor(position int64, id uint) string {
+	return sealCursor(fmt.Sprintf("%d:%d", position, id))
+}
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(cursor string) (int64, uint, error) {
+	raw, err := openCursor(cursor)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("malformed cursor")
+	}
+
+	position, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return position, uint(id), nil
+}
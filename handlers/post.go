@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"adbiz_backend/config"
+
+	"gorm.io/gorm"
+)
+
+type PostHandler struct {
+	db   *gorm.DB
+	feed config.FeedConfig
+}
+
+func NewPostHandler(db *gorm.DB, cfg *config.Config) *PostHandler {
+	return &PostHandler{
+		db:   db,
+		feed: cfg.Feed,
+	}
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/ids"
+	"adbiz_backend/models"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreatePostRequest defines the request structure for creating a post
+type CreatePostRequest struct {
+	Title       string     `json:"title" binding:"required,max=200"`
+	Description *string    `json:"description,omitempty" binding:"omitempty,max=5000"`
+	Price       int64      `json:"price" binding:"min=0"`
+	Currency    string     `json:"currency" binding:"omitempty,len=3,alpha"`
+	ProductType string     `json:"product_type" binding:"required"`      // Category slug
+	Media       []string   `json:"media" binding:"max=10,dive,required"` // Media IDs
+	Status      string     `json:"status" binding:"omitempty,oneof=draft published archived"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// UpdatePostRequest defines the request structure for updating a post
+type UpdatePostRequest struct {
+	Title       string     `json:"title" binding:"omitempty,max=200"`
+	Description *string    `json:"description,omitempty" binding:"omitempty,max=5000"`
+	Price       *int64     `json:"price,omitempty" binding:"omitempty,min=0"`
+	Currency    string     `json:"currency" binding:"omitempty,len=3,alpha"`
+	ProductType string     `json:"product_type"`
+	Media       []string   `json:"media" binding:"omitempty,max=10,dive,required"` // Media IDs
+	Status      string     `json:"status" binding:"omitempty,oneof=draft published archived"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatePost publishes or drafts a post for the authenticated seller's shop
+func (h *PostHandler) CreatePost(c *gin.Context) {
+	var req CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Posts always belong to the caller's own shop
+	var shop models.Shop
+	if result := h.db.Where("user_id = ?", authUserID.(uint)).First(&shop); result.Error != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only sellers with a shop can create posts"})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	if err := validateProductType(h.db, req.ProductType); err != nil {
+		c.JSON(productTypeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateOwnedMedia(h.db, shop.UserID, req.Media...); err != nil {
+		c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	post := models.Post{
+		ShopID:      shop.ID,
+		UserID:      shop.UserID,
+		Title:       req.Title,
+		Description: req.Description,
+		Price:       req.Price,
+		Currency:    "INR",
+		ProductType: req.ProductType,
+		Media:       req.Media,
+		Status:      models.PostStatusDraft,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if req.Currency != "" {
+		post.Currency = strings.ToUpper(req.Currency)
+	}
+	if req.Status != "" {
+		post.Status = req.Status
+	}
+	if post.Status == models.PostStatusPublished {
+		now := time.Now().UTC()
+		post.PublishedAt = &now
+	}
+
+	if err := h.db.Create(&post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post: " + err.Error()})
+		return
+	}
+
+	// Push the post to followers' feeds in the background
+	if post.Status == models.PostStatusPublished {
+		go h.fanOutPost(post)
+	}
+
+	var urls mediaURLs
+	urls.post(&post)
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"post": post,
+	})
+}
+
+// GetPost retrieves a post by ID
+// Drafts, archived and expired posts are only visible to the owner or an admin
+func (h *PostHandler) GetPost(c *gin.Context) {
+	post, ok := h.loadPost(c, true)
+	if !ok {
+		return
+	}
+
+	if !post.IsVisible(time.Now()) && !isOwnerOrAdmin(c, post.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	var urls mediaURLs
+	urls.post(post)
+	urls.sign(c.Request.Context(), h.db)
+
+	if post.IsVisible(time.Now()) {
+		trackPostClick(c, post)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"post": post,
+	})
+}
+
+// UpdatePost updates a post owned by the authenticated seller
+func (h *PostHandler) UpdatePost(c *gin.Context) {
+	post, ok := h.loadPost(c, false)
+	if !ok {
+		return
+	}
+
+	if !isOwnerOrAdmin(c, post.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own posts"})
+		return
+	}
+
+	var req UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	// Update post fields if provided in request
+	if req.Title != "" {
+		post.Title = req.Title
+	}
+	if req.Description != nil {
+		post.Description = req.Description
+	}
+	if req.Price != nil {
+		post.Price = *req.Price
+	}
+	if req.Currency != "" {
+		post.Currency = strings.ToUpper(req.Currency)
+	}
+	if req.ProductType != "" && req.ProductType != post.ProductType {
+		if err := validateProductType(h.db, req.ProductType); err != nil {
+			c.JSON(productTypeErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		post.ProductType = req.ProductType
+	}
+	if req.Media != nil {
+		if err := validateOwnedMedia(h.db, post.UserID, req.Media...); err != nil {
+			c.JSON(mediaErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		post.Media = req.Media
+	}
+	if req.ExpiresAt != nil {
+		post.ExpiresAt = req.ExpiresAt
+	}
+	firstPublish := false
+	if req.Status != "" {
+		post.Status = req.Status
+		if post.Status == models.PostStatusPublished && post.PublishedAt == nil {
+			now := time.Now().UTC()
+			post.PublishedAt = &now
+			firstPublish = true
+		}
+	}
+
+	// Save updated post to database
+	if err := h.db.Save(post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post: " + err.Error()})
+		return
+	}
+
+	if err := cache.InvalidatePostCache(c.Request.Context(), post.PublicID); err != nil {
+		log.Printf("Failed to invalidate post cache: %v", err)
+	}
+
+	// A draft published for the first time goes out to followers' feeds
+	if firstPublish {
+		go h.fanOutPost(*post)
+	}
+
+	var urls mediaURLs
+	urls.post(post)
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"post": post,
+	})
+}
+
+// DeletePost soft deletes a post owned by the authenticated seller
+func (h *PostHandler) DeletePost(c *gin.Context) {
+	post, ok := h.loadPost(c, false)
+	if !ok {
+		return
+	}
+
+	if !isOwnerOrAdmin(c, post.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own posts"})
+		return
+	}
+
+	if err := h.db.Delete(post).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post: " + err.Error()})
+		return
+	}
+
+	if err := cache.InvalidatePostCache(c.Request.Context(), post.PublicID); err != nil {
+		log.Printf("Failed to invalidate post cache: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Post deleted successfully",
+	})
+}
+
+// GetShopPosts lists every post of a shop, including drafts, for its owner
+func (h *PostHandler) GetShopPosts(c *gin.Context) {
+	// Shop resolved and authorized by RequireOwnerOrAdmin and LoadSubjectShop
+	shop := c.MustGet("subject_shop").(*models.Shop)
+
+	query := h.db.Where("shop_id = ?", shop.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var posts []models.Post
+	if result := query.Order("created_at DESC").Find(&posts); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts: " + result.Error.Error()})
+		return
+	}
+
+	var urls mediaURLs
+	for i := range posts {
+		urls.post(&posts[i])
+	}
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts": posts,
+	})
+}
+
+// loadPost reads the post named by the :id path parameter. Posts of deleted shops are
+// treated as missing. Only reads pass cached: writes must start from the stored row, and
+// the cache does not repeat the shop check, relying on invalidateShopPosts instead.
+func (h *PostHandler) loadPost(c *gin.Context, cached bool) (*models.Post, bool) {
+	ctx := c.Request.Context()
+	query := h.db.Joins("JOIN shops ON shops.id = posts.shop_id AND shops.deleted_at IS NULL")
+
+	// :id is the public ID; numeric IDs are not accepted so that posts cannot be enumerated
+	publicID := c.Param("id")
+	if !ids.IsValid(publicID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	if cached {
+		if post, err := cache.GetCachedPost(ctx, publicID); err == nil {
+			return post, true
+		}
+	}
+
+	var post models.Post
+	result := query.Where("posts.public_id = ?", publicID).First(&post)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find post: " + result.Error.Error()})
+		}
+		return nil, false
+	}
+
+	if cached {
+		if err := cache.CachePost(ctx, &post); err != nil {
+			log.Printf("Failed to cache post: %v", err)
+		}
+	}
+
+	return &post, true
+}
+
+// invalidateShopPosts drops the cached posts of a shop, e.g. after the shop is deleted
+func invalidateShopPosts(ctx context.Context, db *gorm.DB, shopID uint) {
+	var postIDs []string
+	if err := db.Model(&models.Post{}).Where("shop_id = ?", shopID).Pluck("public_id", &postIDs).Error; err != nil {
+		log.Printf("Failed to list posts of shop %d: %v", shopID, err)
+		return
+	}
+	if err := cache.InvalidatePostCache(ctx, postIDs...); err != nil {
+		log.Printf("Failed to invalidate post cache: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	fanOutBatchSize   = 1000
+	feedBackfillPosts = 20
+)
+
+// FeedItem is a post in the home feed together with the shop that published it
+type FeedItem struct {
+	Post models.Post  `json:"post"`
+	Shop *ShopSummary `json:"shop,omitempty"`
+}
+
+// GetFeed returns the published posts of the shops the caller follows, newest first
+func (h *PostHandler) GetFeed(c *gin.Context) {
+	// Get authenticated user ID from context
+	authUserID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := authUserID.(uint)
+
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	var cursorScore int64
+	var cursorID uint
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if cursorScore, cursorID, err = decodeCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	// Fan-out on write: posts already pushed to the caller's timeline
+	entries, err := cache.GetFeedEntries(ctx, userID, cursorScore, int64(2*limit+1))
+	if err != nil {
+		log.Printf("Failed to read timeline: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed"})
+		return
+	}
+
+	// Fan-out on read: posts of followed shops with too many followers to push to
+	pulled, err := h.pullCelebrityEntries(ctx, userID, cursorScore, cursorID, limit+1)
+	if err != nil {
+		log.Printf("Failed to read celebrity posts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed"})
+		return
+	}
+
+	candidates := mergeFeedEntries(append(entries, pulled...), cursorScore, cursorID)
+
+	nextCursor := ""
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+		last := candidates[len(candidates)-1]
+		nextCursor = encodeCursor(last.Score, last.PostID)
+	}
+
+	items, err := h.hydrateFeed(userID, candidates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts: " + err.Error()})
+		return
+	}
+
+	var urls mediaURLs
+	for i := range items {
+		urls.post(&items[i].Post)
+		if items[i].Shop != nil {
+			urls.shopSummary(items[i].Shop)
+		}
+		trackPostImpressions(c, &items[i].Post)
+	}
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts":       items,
+		"next_cursor": nextCursor,
+	})
+}
+
+// pullCelebrityEntries reads recent posts of followed high-follower shops from the database
+func (h *PostHandler) pullCelebrityEntries(ctx context.Context, userID uint, cursorScore int64, cursorID uint, limit int) ([]cache.FeedEntry, error) {
+	celebrities, err := cache.GetFeedCelebrities(ctx)
+	if err != nil || len(celebrities) == 0 {
+		return nil, err
+	}
+
+	following, err := loadFollowingSet(h.db, userID, celebrities)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerIDs := make([]uint, 0, len(following))
+	for id := range following {
+		ownerIDs = append(ownerIDs, id)
+	}
+	if len(ownerIDs) == 0 {
+		return nil, nil
+	}
+
+	// Feed scores are milliseconds while published_at has microseconds, so posts are
+	// paged by the truncated time to match the order and cursor of the Redis timelines
+	const publishedMs = "date_trunc('milliseconds', published_at)"
+	query := h.db.Model(&models.Post{}).
+		Where("user_id IN ? AND status = ? AND published_at IS NOT NULL", ownerIDs, models.PostStatusPublished)
+	if cursorScore > 0 {
+		query = query.Where("("+publishedMs+", id) < (?, ?)", time.UnixMilli(cursorScore).UTC(), cursorID)
+	}
+
+	var posts []models.Post
+	if err := query.Order(publishedMs + " DESC, id DESC").Limit(limit).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]cache.FeedEntry, len(posts))
+	for i, post := range posts {
+		entries[i] = cache.FeedEntry{PostID: post.ID, Score: post.PublishedAt.UnixMilli()}
+	}
+	return entries, nil
+}
+
+// mergeFeedEntries dedupes entries, drops those at or after the cursor and sorts them newest first
+func mergeFeedEntries(entries []cache.FeedEntry, cursorScore int64, cursorID uint) []cache.FeedEntry {
+	seen := make(map[uint]bool, len(entries))
+	merged := make([]cache.FeedEntry, 0, len(entries))
+	for _, entry := range entries {
+		if seen[entry.PostID] {
+			continue
+		}
+		if cursorScore > 0 && (entry.Score > cursorScore || (entry.Score == cursorScore && entry.PostID >= cursorID)) {
+			continue
+		}
+		seen[entry.PostID] = true
+		merged = append(merged, entry)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Score != merged[j].Score {
+			return merged[i].Score > merged[j].Score
+		}
+		return merged[i].PostID > merged[j].PostID
+	})
+	return merged
+}
+
+// hydrateFeed loads the posts behind timeline entries, skipping posts that are no longer
+// visible and posts of shops the user has stopped following since they were pushed
+func (h *PostHandler) hydrateFeed(userID uint, entries []cache.FeedEntry) ([]FeedItem, error) {
+	items := make([]FeedItem, 0, len(entries))
+	if len(entries) == 0 {
+		return items, nil
+	}
+
+	postIDs := make([]uint, len(entries))
+	for i, entry := range entries {
+		postIDs[i] = entry.PostID
+	}
+
+	var posts []models.Post
+	if err := h.db.Joins("JOIN shops ON shops.id = posts.shop_id AND shops.deleted_at IS NULL").
+		Where("posts.id IN ?", postIDs).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Post, len(posts))
+	ownerIDs := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+		ownerIDs = append(ownerIDs, post.UserID)
+	}
+
+	following, err := loadFollowingSet(h.db, userID, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	shops, err := loadShopSummaries(h.db, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		post, ok := byID[entry.PostID]
+		if !ok || !post.IsVisible(now) || !following[post.UserID] {
+			continue
+		}
+		items = append(items, FeedItem{Post: post, Shop: shops[post.UserID]})
+	}
+	return items, nil
+}
+
+// fanOutPost pushes a newly published post onto its followers' timelines. Shops above
+// the configured fan-out threshold of followers are marked so feeds pull their posts
+// on read instead.
+func (h *PostHandler) fanOutPost(post models.Post) {
+	if post.PublishedAt == nil {
+		return
+	}
+	ctx := context.Background()
+
+	var followers int64
+	if err := h.db.Model(&models.Follow{}).Where("followee_id = ?", post.UserID).Count(&followers).Error; err != nil {
+		log.Printf("Failed to count followers of user %d: %v", post.UserID, err)
+		return
+	}
+
+	celebrity := followers > int64(h.feed.FanOutThreshold)
+	if err := cache.SetFeedCelebrity(ctx, post.UserID, celebrity); err != nil {
+		log.Printf("Failed to update feed celebrity flag: %v", err)
+	}
+	if celebrity {
+		return
+	}
+
+	entry := cache.FeedEntry{PostID: post.ID, Score: post.PublishedAt.UnixMilli()}
+	var lastFollowerID uint
+	for {
+		var followerIDs []uint
+		if err := h.db.Model(&models.Follow{}).
+			Where("followee_id = ? AND follower_id > ?", post.UserID, lastFollowerID).
+			Order("follower_id").Limit(fanOutBatchSize).
+			Pluck("follower_id", &followerIDs).Error; err != nil {
+			log.Printf("Failed to list followers of user %d: %v", post.UserID, err)
+			return
+		}
+		if len(followerIDs) == 0 {
+			return
+		}
+
+		if err := cache.AddToFeeds(ctx, followerIDs, entry); err != nil {
+			log.Printf("Failed to fan out post %d: %v", post.ID, err)
+			return
+		}
+		lastFollowerID = followerIDs[len(followerIDs)-1]
+	}
+}
+
+// BackfillFeed copies the recent posts of a newly followed user into the follower's timeline
+func BackfillFeed(db *gorm.DB, followerID, followeeID uint) {
+	var posts []models.Post
+	if err := db.Where("user_id = ? AND status = ? AND published_at IS NOT NULL", followeeID, models.PostStatusPublished).
+		Order("published_at DESC").Limit(feedBackfillPosts).Find(&posts).Error; err != nil {
+		log.Printf("Failed to load posts of user %d: %v", followeeID, err)
+		return
+	}
+
+	entries := make([]cache.FeedEntry, len(posts))
+	for i, post := range posts {
+		entries[i] = cache.FeedEntry{PostID: post.ID, Score: post.PublishedAt.UnixMilli()}
+	}
+	if err := cache.AddToFeeds(context.Background(), []uint{followerID}, entries...); err != nil {
+		log.Printf("Failed to backfill feed of user %d: %v", followerID, err)
+	}
+}
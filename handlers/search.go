@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	searchKindShop = "shop"
+	searchKindPost = "post"
+
+	maxSearchQueryLength = 100
+	maxSearchTerms       = 8
+)
+
+type SearchHandler struct {
+	db *gorm.DB
+}
+
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{
+		db: db,
+	}
+}
+
+// SearchResult is a shop or a post matching the search query; posts carry their shop
+type SearchResult struct {
+	Kind  string       `json:"kind"` // "shop" or "post"
+	Shop  *ShopSummary `json:"shop"`
+	Post  *models.Post `json:"post,omitempty"`
+	Score int64        `json:"score"`
+}
+
+// searchHit is a row of the ranked search query before hydration
+type searchHit struct {
+	Kind  string
+	ID    uint
+	Score int64
+}
+
+// The search_vector columns are generated by Postgres from the row itself, so they
+// change in the same transaction as any create or update of a shop or post.
+const searchSQL = `
+SELECT kind, id, score FROM (
+	SELECT 'shop' AS kind, shops.id,
+		((ts_rank(shops.search_vector, to_tsquery('simple', @tsquery))
+			+ GREATEST(word_similarity(@q, shops.shop_name), word_similarity(@q, shops.shop_username))) * 1000000)::bigint AS score
+	FROM shops
+	JOIN users ON users.id = shops.user_id AND users.deleted_at IS NULL
+	WHERE shops.deleted_at IS NULL
+		AND (shops.search_vector @@ to_tsquery('simple', @tsquery)
+			OR @q <% shops.shop_name OR @q <% shops.shop_username)
+		/* shop filters */
+	UNION ALL
+	SELECT 'post' AS kind, posts.id,
+		((ts_rank(posts.search_vector, to_tsquery('simple', @tsquery))
+			+ word_similarity(@q, posts.title)) * 1000000)::bigint AS score
+	FROM posts
+	JOIN shops ON shops.id = posts.shop_id AND shops.deleted_at IS NULL
+	JOIN users ON users.id = shops.user_id AND users.deleted_at IS NULL
+	WHERE posts.deleted_at IS NULL
+		AND posts.status = @published AND (posts.expires_at IS NULL OR posts.expires_at > @now)
+		AND (posts.search_vector @@ to_tsquery('simple', @tsquery) OR @q <% posts.title)
+		/* post filters */
+) hits
+/* cursor filter */
+ORDER BY score DESC, kind DESC, id DESC
+LIMIT @limit`
+
+// Search ranks shops and posts matching q by text relevance and similarity, with
+// prefix matching on every word and typo tolerance on names, usernames and titles.
+// Results can be limited to a type, a category and its subcategories, or a radius
+// around lat/lng.
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if len(q) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength)})
+		return
+	}
+	tsquery := prefixTSQuery(q)
+	if tsquery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must contain letters or digits"})
+		return
+	}
+
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	kind := c.DefaultQuery("type", "all")
+	if kind != "all" && kind != "shops" && kind != "posts" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be all, shops or posts"})
+		return
+	}
+
+	args := []interface{}{
+		sql.Named("q", q),
+		sql.Named("tsquery", tsquery),
+		sql.Named("published", models.PostStatusPublished),
+		sql.Named("now", time.Now().UTC()),
+		sql.Named("limit", limit+1),
+	}
+	var shopFilters, postFilters []string
+	switch kind {
+	case "shops":
+		postFilters = append(postFilters, "AND FALSE")
+	case "posts":
+		shopFilters = append(shopFilters, "AND FALSE")
+	}
+
+	if category := c.Query("category"); category != "" {
+		slugs, err := categorySubtreeSlugs(h.db, category)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find category: " + err.Error()})
+			return
+		}
+		if len(slugs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+		args = append(args, sql.Named("slugs", slugs))
+		shopFilters = append(shopFilters, "AND shops.product_type IN @slugs")
+		postFilters = append(postFilters, "AND posts.product_type IN @slugs")
+	}
+
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		shopIDs, ok := h.nearbyShopIDs(c)
+		if !ok {
+			return
+		}
+		if len(shopIDs) == 0 {
+			c.JSON(http.StatusOK, gin.H{"results": []SearchResult{}, "next_cursor": ""})
+			return
+		}
+		args = append(args, sql.Named("shop_ids", shopIDs))
+		shopFilters = append(shopFilters, "AND shops.id IN @shop_ids")
+		postFilters = append(postFilters, "AND posts.shop_id IN @shop_ids")
+	}
+
+	cursorFilter := ""
+	if cursor := c.Query("cursor"); cursor != "" {
+		score, cursorKind, id, err := decodeSearchCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		args = append(args, sql.Named("cursor_score", score), sql.Named("cursor_kind", cursorKind), sql.Named("cursor_id", id))
+		cursorFilter = "WHERE (score, kind, id) < (@cursor_score, @cursor_kind, @cursor_id)"
+	}
+
+	var hits []searchHit
+	query := strings.NewReplacer(
+		"/* shop filters */", strings.Join(shopFilters, " "),
+		"/* post filters */", strings.Join(postFilters, " "),
+		"/* cursor filter */", cursorFilter,
+	).Replace(searchSQL)
+	if err := h.db.Raw(query, args...).Scan(&hits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search: " + err.Error()})
+		return
+	}
+
+	// The extra row only tells us whether another page exists
+	nextCursor := ""
+	if len(hits) > limit {
+		hits = hits[:limit]
+		last := hits[len(hits)-1]
+		nextCursor = encodeSearchCursor(last.Score, last.Kind, last.ID)
+	}
+
+	results, err := h.hydrateSearchHits(hits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search results: " + err.Error()})
+		return
+	}
+
+	var urls mediaURLs
+	for _, result := range results {
+		if result.Shop != nil {
+			urls.shopSummary(result.Shop)
+		}
+		if result.Post != nil {
+			urls.post(result.Post)
+			trackPostImpressions(c, result.Post)
+		}
+	}
+	urls.sign(c.Request.Context(), h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":     results,
+		"next_cursor": nextCursor,
+	})
+}
+
+// nearbyShopIDs resolves the lat, lng and radius query parameters to the IDs of the
+// shops in range, writing the error response when they are invalid
+func (h *SearchHandler) nearbyShopIDs(c *gin.Context) ([]uint, bool) {
+	lat, lng, radius, ok := nearbyQuery(c)
+	if !ok {
+		return nil, false
+	}
+
+	nearby, err := cache.SearchNearbyShops(c.Request.Context(), lat, lng, radius, nearbyCandidateLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search nearby shops: " + err.Error()})
+		return nil, false
+	}
+	shopIDs := make([]uint, len(nearby))
+	for i, n := range nearby {
+		shopIDs[i] = n.ShopID
+	}
+	return shopIDs, true
+}
+
+// hydrateSearchHits loads the shops and posts of the hits, keeping their order
+func (h *SearchHandler) hydrateSearchHits(hits []searchHit) ([]SearchResult, error) {
+	var shopIDs, postIDs []uint
+	for _, hit := range hits {
+		if hit.Kind == searchKindShop {
+			shopIDs = append(shopIDs, hit.ID)
+		} else {
+			postIDs = append(postIDs, hit.ID)
+		}
+	}
+
+	posts := make(map[uint]*models.Post, len(postIDs))
+	if len(postIDs) > 0 {
+		var rows []models.Post
+		if err := h.db.Where("id IN ?", postIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			posts[rows[i].ID] = &rows[i]
+			shopIDs = append(shopIDs, rows[i].ShopID)
+		}
+	}
+
+	shops := make(map[uint]*ShopSummary, len(shopIDs))
+	if len(shopIDs) > 0 {
+		var rows []models.Shop
+		if err := h.db.Where("id IN ?", shopIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, shop := range rows {
+			summary := newShopSummary(&shop)
+			shops[shop.ID] = &summary
+		}
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		result := SearchResult{Kind: hit.Kind, Score: hit.Score}
+		if hit.Kind == searchKindShop {
+			result.Shop = shops[hit.ID]
+		} else {
+			post, ok := posts[hit.ID]
+			if !ok {
+				continue // Deleted since the search ran
+			}
+			result.Post = post
+			result.Shop = shops[post.ShopID]
+		}
+		if result.Shop == nil {
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// prefixTSQuery turns free text into a tsquery that requires every word as a prefix,
+// e.g. "red shoe" becomes "red:* & shoe:*". Characters with a meaning in tsquery
+// syntax are dropped.
+func prefixTSQuery(q string) string {
+	// Apostrophes join word parts ("kid's" is searched as "kids")
+	q = strings.NewReplacer("'", "", "\u2019", "").Replace(strings.ToLower(q))
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// encodeSearchCursor packs the sort key of the last search hit into an opaque string
+func encodeSearchCursor(score int64, kind string, id uint) string {
+	return sealCursor(fmt.Sprintf("%d:%s:%d", score, kind, id))
+}
+
+// decodeSearchCursor reverses encodeSearchCursor
+func decodeSearchCursor(cursor string) (int64, string, uint, error) {
+	raw, err := openCursor(cursor)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 || (parts[1] != searchKindShop && parts[1] != searchKindPost) {
+		return 0, "", 0, fmt.Errorf("malformed cursor")
+	}
+
+	score, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	return score, parts[1], uint(id), nil
+}
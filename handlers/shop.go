@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"gorm.io/gorm"
+)
+
+type ShopHandler struct {
+	db *gorm.DB
+}
+
+func NewShopHandler(db *gorm.DB) *ShopHandler {
+	return &ShopHandler{
+		db: db,
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"adbiz_backend/analytics"
+	"adbiz_backend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	analyticsTopPosts      = 10
+	defaultAnalyticsDays   = 30
+	maxAnalyticsHourPoints = 31 * 24
+	maxAnalyticsDayPoints  = 366
+)
+
+// ShopAnalytics is the engagement of a shop over [From, To), bucketed by Interval
+type ShopAnalytics struct {
+	ShopID    string            `json:"shop_id"`
+	Interval  string            `json:"interval"` // "hour" or "day", buckets start on UTC hours or days
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Followers int64             `json:"followers"` // Current follower count
+	Totals    models.StatCounts `json:"totals"`
+	Series    []models.ShopStat `json:"series"` // One entry per bucket, zero-filled
+	TopPosts  []PostEngagement  `json:"top_posts"`
+}
+
+// PostEngagement is the engagement of one post over the requested range, counted per UTC day
+type PostEngagement struct {
+	PublicID    string `json:"public_id"`
+	Title       string `json:"title"`
+	Impressions int64  `json:"impressions"`
+	Clicks      int64  `json:"clicks"`
+}
+
+// GetShopAnalytics returns profile views, post engagement and follower growth of a shop.
+// from and to accept RFC 3339 timestamps or YYYY-MM-DD dates; the latest minute or so of
+// events may not be rolled up yet.
+func (h *ShopHandler) GetShopAnalytics(c *gin.Context) {
+	// Shop resolved and authorized by RequireOwnerOrAdmin and LoadSubjectShop
+	shop := c.MustGet("subject_shop").(*models.Shop)
+
+	interval := c.DefaultQuery("interval", models.StatPeriodDay)
+	var step time.Duration
+	var maxPoints int
+	switch interval {
+	case models.StatPeriodHour:
+		step, maxPoints = time.Hour, maxAnalyticsHourPoints
+	case models.StatPeriodDay:
+		step, maxPoints = 24*time.Hour, maxAnalyticsDayPoints
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be hour or day"})
+		return
+	}
+
+	now := time.Now().UTC()
+	to := now
+	if value := c.Query("to"); value != "" {
+		t, err := parseAnalyticsTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultAnalyticsDays)
+	if value := c.Query("from"); value != "" {
+		t, err := parseAnalyticsTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+			return
+		}
+		from = t
+	}
+
+	// Round outwards to whole buckets so the partial first and last buckets are included
+	from = from.Truncate(step)
+	if end := to.Truncate(step); end.Before(to) {
+		to = end.Add(step)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if points := int(to.Sub(from) / step); points > maxPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d %s buckets can be requested", maxPoints, interval)})
+		return
+	}
+
+	var stats []models.ShopStat
+	if err := h.db.Where("shop_id = ? AND period = ? AND bucket_start >= ? AND bucket_start < ?",
+		shop.ID, interval, from, to).Order("bucket_start").Find(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load analytics: " + err.Error()})
+		return
+	}
+
+	result := ShopAnalytics{
+		ShopID:   shop.ShopID,
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Series:   make([]models.ShopStat, 0, int(to.Sub(from)/step)),
+		TopPosts: []PostEngagement{},
+	}
+	next := 0
+	for start := from; start.Before(to); start = start.Add(step) {
+		bucket := models.ShopStat{BucketStart: start}
+		if next < len(stats) && stats[next].BucketStart.Equal(start) {
+			bucket.StatCounts = stats[next].StatCounts
+			next++
+		}
+		addStatCounts(&result.Totals, &bucket.StatCounts)
+		result.Series = append(result.Series, bucket)
+	}
+
+	followers, err := countFollowers(h.db, shop.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count followers: " + err.Error()})
+		return
+	}
+	result.Followers = followers
+
+	// Post stats are daily, so the range is widened to whole days
+	if err := h.db.Table("post_stats").
+		Select("posts.public_id, posts.title, SUM(post_stats.impressions) AS impressions, SUM(post_stats.clicks) AS clicks").
+		Joins("JOIN posts ON posts.id = post_stats.post_id AND posts.deleted_at IS NULL").
+		Where("post_stats.shop_id = ? AND post_stats.day >= ? AND post_stats.day < ?",
+			shop.ID, from.Truncate(24*time.Hour), to).
+		Group("posts.id, posts.public_id, posts.title").
+		Order("clicks DESC, impressions DESC, posts.id").
+		Limit(analyticsTopPosts).
+		Scan(&result.TopPosts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load post analytics: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"analytics": result,
+	})
+}
+
+// parseAnalyticsTime parses an RFC 3339 timestamp or a YYYY-MM-DD date in UTC
+func parseAnalyticsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+func addStatCounts(total, counts *models.StatCounts) {
+	total.ProfileViews += counts.ProfileViews
+	total.PostImpressions += counts.PostImpressions
+	total.PostClicks += counts.PostClicks
+	total.Follows += counts.Follows
+	total.Unfollows += counts.Unfollows
+}
+
+// viewerIsOwner reports whether the caller owns the shop; owners' own activity is not tracked
+func viewerIsOwner(c *gin.Context, ownerID uint) bool {
+	authUserID, exists := c.Get("user_id")
+	return exists && authUserID.(uint) == ownerID
+}
+
+// trackProfileView records a view of a shop profile by anyone but its owner
+func trackProfileView(c *gin.Context, shop *models.Shop) {
+	if !viewerIsOwner(c, shop.UserID) {
+		analytics.Track(analytics.Event{Metric: analytics.ProfileView, ShopID: shop.ID})
+	}
+}
+
+// trackPostImpressions records posts shown to the caller in a list
+func trackPostImpressions(c *gin.Context, posts ...*models.Post) {
+	for _, post := range posts {
+		if !viewerIsOwner(c, post.UserID) {
+			analytics.Track(analytics.Event{Metric: analytics.PostImpression, ShopID: post.ShopID, PostID: post.ID})
+		}
+	}
+}
+
+// trackPostClick records the caller opening a post
+func trackPostClick(c *gin.Context, post *models.Post) {
+	if !viewerIsOwner(c, post.UserID) {
+		analytics.Track(analytics.Event{Metric: analytics.PostClick, ShopID: post.ShopID, PostID: post.ID})
+	}
+}
+
+// trackFollow records a follow or unfollow of a user's shop. It looks the shop up,
+// so handlers run it in a goroutine.
+func trackFollow(db *gorm.DB, followeeID uint, metric string) {
+	var shop models.Shop
+	err := db.Select("id").Where("user_id = ?", followeeID).Take(&shop).Error
+	if err == gorm.ErrRecordNotFound {
+		return // Buyers have no shop analytics
+	}
+	if err != nil {
+		log.Printf("Failed to find shop of user %d for analytics: %v", followeeID, err)
+		return
+	}
+	analytics.Track(analytics.Event{Metric: metric, ShopID: shop.ID})
+}
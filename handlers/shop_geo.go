@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"adbiz_backend/cache"
+	"adbiz_backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultNearbyRadiusKm = 5.0
+	maxNearbyRadiusKm     = 50.0
+	// nearbyCandidateLimit bounds how many shops are read from the GEO set when
+	// results are filtered by category afterwards
+	nearbyCandidateLimit = 1000
+)
+
+var errShopCountry = errors.New("address.country must be a two-letter ISO 3166-1 code")
+
+// ShopLocationRequest holds the optional location fields of the shop requests
+type ShopLocationRequest struct {
+	Latitude  *float64        `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,latitude"`
+	Longitude *float64        `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,longitude"`
+	Address   *models.Address `json:"address,omitempty"`
+}
+
+// NearbyShopSummary is a shop in the nearby search results
+type NearbyShopSummary struct {
+	ShopSummary
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	City       *string `json:"city,omitempty"`
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// applyShopLocation copies the location fields that were sent onto the shop
+func applyShopLocation(shop *models.Shop, req *ShopLocationRequest) error {
+	if req.Address != nil {
+		if country := req.Address.Country; country != nil {
+			upper := strings.ToUpper(*country)
+			if len(upper) != 2 || upper[0] < 'A' || upper[0] > 'Z' || upper[1] < 'A' || upper[1] > 'Z' {
+				return errShopCountry
+			}
+			req.Address.Country = &upper
+		}
+		shop.Address = *req.Address
+	}
+	if req.Latitude != nil && req.Longitude != nil {
+		shop.Latitude = req.Latitude
+		shop.Longitude = req.Longitude
+	}
+	return nil
+}
+
+// syncShopLocation keeps the Redis GEO set in line with the shop after it is saved,
+// deleted or reactivated. Failures are logged; the set is rebuilt by a reindex.
+func syncShopLocation(ctx context.Context, shop *models.Shop) {
+	var err error
+	if shop.HasCoordinates() && !shop.DeletedAt.Valid {
+		err = cache.SetShopLocation(ctx, shop.ID, *shop.Latitude, *shop.Longitude)
+	} else {
+		err = cache.RemoveShopLocation(ctx, shop.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to sync location of shop %d: %v", shop.ID, err)
+	}
+}
+
+// ReindexShopLocations rebuilds the GEO set from every active shop with coordinates and
+// returns how many shops it holds
+func ReindexShopLocations(ctx context.Context, db *gorm.DB) (int, error) {
+	var shops []models.Shop
+	if err := db.WithContext(ctx).Select("id", "latitude", "longitude").
+		Where("latitude IS NOT NULL AND longitude IS NOT NULL").Find(&shops).Error; err != nil {
+		return 0, err
+	}
+
+	locations := make([]cache.ShopLocation, len(shops))
+	for i, shop := range shops {
+		locations[i] = cache.ShopLocation{ShopID: shop.ID, Latitude: *shop.Latitude, Longitude: *shop.Longitude}
+	}
+	if err := cache.RebuildShopLocations(ctx, locations); err != nil {
+		return 0, err
+	}
+	return len(locations), nil
+}
+
+// nearbyQuery reads the lat, lng and radius query parameters and writes the error
+// response when they are invalid
+func nearbyQuery(c *gin.Context) (float64, float64, float64, bool) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat must be a latitude between -90 and 90"})
+		return 0, 0, 0, false
+	}
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lng must be a longitude between -180 and 180"})
+		return 0, 0, 0, false
+	}
+
+	radius := defaultNearbyRadiusKm
+	if raw := c.Query("radius"); raw != "" {
+		radius, err = strconv.ParseFloat(raw, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("radius must be a distance in km between 0 and %g", maxNearbyRadiusKm)})
+			return 0, 0, 0, false
+		}
+	}
+	return lat, lng, radius, true
+}
+
+// GetNearbyShops lists the shops within radius km of lat/lng, nearest first,
+// optionally limited to a category and its subcategories
+func (h *ShopHandler) GetNearbyShops(c *gin.Context) {
+	lat, lng, radius, ok := nearbyQuery(c)
+	if !ok {
+		return
+	}
+
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+
+	var slugs []string
+	var err error
+	candidates := limit
+	if category := c.Query("category"); category != "" {
+		slugs, err = categorySubtreeSlugs(h.db, category)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find category: " + err.Error()})
+			return
+		}
+		if len(slugs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+		candidates = nearbyCandidateLimit
+	}
+
+	ctx := c.Request.Context()
+	nearby, err := cache.SearchNearbyShops(ctx, lat, lng, radius, candidates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search nearby shops: " + err.Error()})
+		return
+	}
+	if len(nearby) == 0 {
+		c.JSON(http.StatusOK, gin.H{"shops": []NearbyShopSummary{}})
+		return
+	}
+
+	shopIDs := make([]uint, len(nearby))
+	for i, n := range nearby {
+		shopIDs[i] = n.ShopID
+	}
+
+	query := h.db.Joins("JOIN users ON users.id = shops.user_id AND users.deleted_at IS NULL").
+		Where("shops.id IN ?", shopIDs)
+	if slugs != nil {
+		query = query.Where("shops.product_type IN ?", slugs)
+	}
+	var shops []models.Shop
+	if err := query.Find(&shops).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shops: " + err.Error()})
+		return
+	}
+
+	byID := make(map[uint]*models.Shop, len(shops))
+	for i := range shops {
+		byID[shops[i].ID] = &shops[i]
+	}
+
+	// Keep the distance order of the GEO search
+	results := make([]NearbyShopSummary, 0, limit)
+	for _, n := range nearby {
+		shop, ok := byID[n.ShopID]
+		if !ok || !shop.HasCoordinates() {
+			continue
+		}
+		results = append(results, NearbyShopSummary{
+			ShopSummary: newShopSummary(shop),
+			Latitude:    *shop.Latitude,
+			Longitude:   *shop.Longitude,
+			City:        shop.Address.City,
+			DistanceKm:  n.DistanceKm,
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+
+	var urls mediaURLs
+	for i := range results {
+		urls.shopSummary(&results[i].ShopSummary)
+	}
+	urls.sign(ctx, h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"shops": results,
+	})
+}
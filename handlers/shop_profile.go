@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const shopProfileRecentPosts = 10
+
+// ShopProfile is the public view of a shop; it never includes the owner's mobile number
+type ShopProfile struct {
+	ShopID        string        `json:"shop_id"`
+	ShopName      string        `json:"shop_name"`
+	ShopUsername  string        `json:"shop_username"`
+	Bio           *string       `json:"bio,omitempty"`
+	ProductType   string        `json:"product_type"`
+	Location      *string       `json:"location,omitempty"`
+	ShopPhoto     *string       `json:"shop_photo,omitempty"`
+	ShopPhotoURL  *string       `json:"shop_photo_url,omitempty"`
+	OwnerID       string        `json:"owner_id"` // Public ID of the owner, for the follower and following lists
+	FollowerCount int64         `json:"follower_count"`
+	IsFollowing   bool          `json:"is_following"` // Whether the caller follows the shop, false when anonymous
+	RecentPosts   []models.Post `json:"recent_posts"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// GetShopProfile returns the public profile of a shop by its username, in any letter case
+func (h *ShopHandler) GetShopProfile(c *gin.Context) {
+	username := c.Param("shop_username")
+
+	var shop models.Shop
+	if result := h.db.Where("LOWER(shop_username) = LOWER(?)", username).First(&shop); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find shop: " + result.Error.Error()})
+		}
+		return
+	}
+
+	profile, err := buildShopProfile(c, h.db, &shop)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shop profile: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shop": profile,
+	})
+}
+
+// GetShopByID returns the public profile of a shop by its ShopID. ShopIDs replaced by the
+// opaque ID migration still resolve until their alias expires, with the canonical URL in Link.
+func (h *ShopHandler) GetShopByID(c *gin.Context) {
+	shopID := c.Param("shop_id")
+
+	var shop models.Shop
+	result := h.db.Where("shop_id = ?", shopID).First(&shop)
+	if result.Error == gorm.ErrRecordNotFound {
+		var alias models.ShopIDAlias
+		if err := h.db.Where("old_shop_id = ? AND expires_at > ?", shopID, time.Now().UTC()).
+			First(&alias).Error; err == nil {
+			result = h.db.First(&shop, alias.ShopRefID)
+			if result.Error == nil {
+				c.Header("Deprecation", "true")
+				c.Header("Sunset", alias.ExpiresAt.Format(http.TimeFormat))
+				c.Header("Link", fmt.Sprintf("</api/v1/shops/by-id/%s>; rel=\"canonical\"", shop.ShopID))
+			}
+		} else if err != gorm.ErrRecordNotFound {
+			result.Error = err
+		}
+	}
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find shop: " + result.Error.Error()})
+		}
+		return
+	}
+
+	profile, err := buildShopProfile(c, h.db, &shop)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shop profile: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shop": profile,
+	})
+}
+
+// countFollowers returns the number of active users following a user
+func countFollowers(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Table("follows").
+		Joins("JOIN users ON users.id = follows.follower_id AND users.deleted_at IS NULL").
+		Where("follows.followee_id = ?", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// buildShopProfile gathers the owner's public ID, follower count, recent posts and follow
+// state for a shop and records the profile view
+func buildShopProfile(c *gin.Context, db *gorm.DB, shop *models.Shop) (*ShopProfile, error) {
+	var ownerID string
+	if err := db.Model(&models.User{}).Where("id = ?", shop.UserID).Select("public_id").Scan(&ownerID).Error; err != nil {
+		return nil, err
+	}
+
+	followerCount, err := countFollowers(db, shop.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []models.Post
+	if err := db.Where("shop_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)",
+		shop.ID, models.PostStatusPublished, time.Now().UTC()).
+		Order("published_at DESC").Limit(shopProfileRecentPosts).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	isFollowing := false
+	if authUserID, exists := c.Get("user_id"); exists {
+		following, err := loadFollowingSet(db, authUserID.(uint), []uint{shop.UserID})
+		if err != nil {
+			return nil, err
+		}
+		isFollowing = following[shop.UserID]
+	}
+
+	profile := &ShopProfile{
+		ShopID:        shop.ShopID,
+		ShopName:      shop.ShopName,
+		ShopUsername:  shop.ShopUsername,
+		Bio:           shop.Bio,
+		ProductType:   shop.ProductType,
+		Location:      shop.Location,
+		ShopPhoto:     shop.ShopPhoto,
+		OwnerID:       ownerID,
+		FollowerCount: followerCount,
+		IsFollowing:   isFollowing,
+		RecentPosts:   posts,
+		CreatedAt:     shop.CreatedAt,
+	}
+
+	var urls mediaURLs
+	urls.add(shop.ShopPhoto, avatarVariant, func(url string) { profile.ShopPhotoURL = &url })
+	for i := range profile.RecentPosts {
+		urls.post(&profile.RecentPosts[i])
+	}
+	urls.sign(c.Request.Context(), db)
+
+	trackProfileView(c, shop)
+	for i := range profile.RecentPosts {
+		trackPostImpressions(c, &profile.RecentPosts[i])
+	}
+
+	return profile, nil
+}
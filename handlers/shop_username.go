@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"adbiz_backend/models"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	errShopUsernameFormat   = errors.New("shop username must be 3-30 letters, digits, '_' or '.', and cannot start or end with '.' or contain '..'")
+	errShopUsernameReserved = errors.New("shop username is reserved")
+	errShopUsernameTaken    = errors.New("shop username is already taken")
+)
+
+var shopUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.]{3,30}$`)
+
+// reservedShopUsernames would clash with routes or impersonate the platform
+var reservedShopUsernames = map[string]bool{
+	"admin": true, "administrator": true, "adbiz": true, "api": true, "app": true,
+	"help": true, "support": true, "login": true, "logout": true, "register": true,
+	"settings": true, "shop": true, "shops": true, "user": true, "users": true,
+	"me": true, "root": true, "system": true, "nearby": true, "search": true,
+	"feed": true, "official": true, "null": true, "undefined": true,
+}
+
+// validateShopUsername checks the format of a shop username and that no other shop,
+// including soft-deleted ones, uses it in any letter case
+func validateShopUsername(db *gorm.DB, username string, excludeShopID uint) error {
+	if !shopUsernamePattern.MatchString(username) ||
+		strings.HasPrefix(username, ".") || strings.HasSuffix(username, ".") || strings.Contains(username, "..") {
+		return errShopUsernameFormat
+	}
+
+	if reservedShopUsernames[strings.ToLower(username)] {
+		return errShopUsernameReserved
+	}
+
+	var count int64
+	if err := db.Unscoped().Model(&models.Shop{}).
+		Where("LOWER(shop_username) = LOWER(?) AND id <> ?", username, excludeShopID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errShopUsernameTaken
+	}
+	return nil
+}
+
+// shopUsernameErrorStatus maps a validateShopUsername error to an HTTP status
+func shopUsernameErrorStatus(err error) int {
+	switch err {
+	case errShopUsernameFormat, errShopUsernameReserved:
+		return http.StatusBadRequest
+	case errShopUsernameTaken:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,16 @@
+package ids
+
+import (
+	"github.com/oklog/ulid/v2"
+)
+
+// New returns an opaque, URL-safe identifier that sorts by creation time
+func New() string {
+	return ulid.Make().String()
+}
+
+// IsValid reports whether s was produced by New
+func IsValid(s string) bool {
+	_, err := ulid.ParseStrict(s)
+	return err == nil
+}
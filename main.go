@@ -2,13 +2,11 @@ package main
 
 import (
 	"adbiz_backend/config"
-	"adbiz_backend/router"
-	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"os/signal"
-	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -20,67 +18,71 @@ func init() {
 	}
 }
 
-func main() {
+const usage = `usage: adbiz_backend [flags] [command]
+
+Settings are read from the YAML file given by --config, then from the environment
+(and .env), then from the flags below, each overriding the one before.
+
+flags:
+  --config FILE                   YAML settings file, also CONFIG_FILE
+  --env NAME                      development, staging or production, also ENVIRONMENT
+  --port N                        HTTP port, also PORT
+  --database-url URL              Postgres connection string, also DATABASE_URL
+  --redis-url HOST:PORT           Redis address, also REDIS_URL
+  --migrate-on-start              apply pending migrations when serving, also MIGRATE_ON_START
+
+commands:
+  serve                           start the HTTP server (the default)
+  migrate up|down|status          manage schema migrations
+  seed                            add demo accounts, shops and posts (not in production)
+  create-admin <mobile> --name N  create an admin account, --promote makes an existing user admin
+  user show <mobile>              print an account with its shop and follow counts
+  user deactivate <mobile>        soft delete an account and its shop and revoke its sessions
+  user reactivate <mobile>        restore a deactivated account and its shop
+  shop reindex                    rebuild the Redis GEO set of shop locations
+  cache flush --prefix P          delete Redis keys starting with P, sign-in state
+                                  included only with --force
+  config dump                     print the effective settings with secrets redacted`
+
+// commands are the subcommands of the binary; each returns the process exit code
+var commands = map[string]func(cfg *config.Config, args []string) int{
+	"serve":        runServe,
+	"migrate":      runMigrate,
+	"seed":         runSeed,
+	"create-admin": runCreateAdmin,
+	"user":         runUser,
+	"shop":         runShop,
+	"cache":        runCache,
+	"config":       runConfig,
+}
 
-	// Setup database
-	if err := config.SetupDatabase(); err != nil {
-		log.Fatalf("Failed to setup database: %v", err)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "help" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(0)
 	}
 
-	// Setup Redis
-	if err := config.SetupRedis(); err != nil {
-		log.Fatalf("Failed to setup Redis: %v", err)
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(0)
 	}
-	defer config.CloseRedis()
-
-	// Setup router
-	router := router.SetupRouter()
-
-	// Configure port
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	readTimeout, _ := time.ParseDuration(os.Getenv("READ_TIMEOUT"))
-	writeTimeout, _ := time.ParseDuration(os.Getenv("WRITE_TIMEOUT"))
-	idleTimeout, _ := time.ParseDuration(os.Getenv("IDLE_TIMEOUT"))
-
-	// Create server with timeouts
-	srv := &http.Server{
-		Addr:         ":" + port,
-		Handler:      router,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		IdleTimeout:  idleTimeout,
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
 	}
-
-	// Start server in a goroutine
-	go func() {
-		log.Printf("Server starting on http://localhost:%s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		if name == "help" {
+			os.Exit(0)
 		}
-	}()
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-
-	// Graceful shutdown
-	log.Println("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
-	}
-
-	log.Println("Server exiting")
-
-	// Close database connection
-	if sqlDB, err := config.Db.DB(); err == nil {
-		sqlDB.Close()
+		os.Exit(2)
 	}
+	os.Exit(run(cfg, args))
 }
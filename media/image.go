@@ -0,0 +1,143 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+const (
+	// maxPixels rejects images that would take too much memory to decode
+	maxPixels   = 40_000_000
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG and WebP images are supported")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+)
+
+// Variant is a thumbnail size, bounded by the length of its longest side
+type Variant struct {
+	Name    string
+	MaxSide int
+}
+
+// Variants are generated for every upload that is larger than them
+var Variants = []Variant{
+	{Name: "small", MaxSide: 160},
+	{Name: "medium", MaxSide: 480},
+	{Name: "large", MaxSide: 1080},
+}
+
+// Image is an encoded image ready to be stored
+type Image struct {
+	Name        string // "original" or a Variant name
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// Process decodes an uploaded image, applies its EXIF orientation and re-encodes it,
+// which drops EXIF and all other metadata, then renders the thumbnail variants.
+// PNG stays PNG to keep transparency; JPEG and WebP become JPEG.
+func Process(data []byte) ([]Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+
+	encode := encodeJPEG
+	if contentType == "image/png" {
+		encode = encodePNG
+	}
+
+	original, err := encode("original", src)
+	if err != nil {
+		return nil, err
+	}
+	images := []Image{original}
+
+	bounds := src.Bounds()
+	for _, variant := range Variants {
+		width, height := fit(bounds.Dx(), bounds.Dy(), variant.MaxSide)
+		if width == bounds.Dx() && height == bounds.Dy() {
+			continue // Never upscale
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+		thumbnail, err := encode(variant.Name, dst)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, thumbnail)
+	}
+	return images, nil
+}
+
+// fit scales width and height down so the longest side is at most maxSide
+func fit(width, height, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+func encodeJPEG(name string, img image.Image) (Image, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Image{}, err
+	}
+	return newImage(name, buf.Bytes(), "image/jpeg", "jpg", img), nil
+}
+
+func encodePNG(name string, img image.Image) (Image, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return Image{}, err
+	}
+	return newImage(name, buf.Bytes(), "image/png", "png", img), nil
+}
+
+func newImage(name string, data []byte, contentType, extension string, img image.Image) Image {
+	bounds := img.Bounds()
+	return Image{
+		Name:        name,
+		Data:        data,
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}
+}
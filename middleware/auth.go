@@ -1,32 +1,78 @@
 package middleware
 
 import (
+	"adbiz_backend/cache"
 	"adbiz_backend/handlers"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		if status, message := authenticate(c); status != http.StatusOK {
+			c.JSON(status, gin.H{"error": message})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		claims, err := handlers.VerifyToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
-			c.Abort()
-			return
+// OptionalAuth identifies the caller when a valid token is sent, and otherwise
+// lets the request through anonymously
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticate(c)
 		}
-
-		userID := uint(claims["user_id"].(float64))
-		c.Set("user_id", userID)
 		c.Next()
 	}
 }
+
+// authenticate verifies the bearer token and stores its claims in the context.
+// It returns the HTTP status and error message to use when the token is rejected.
+func authenticate(c *gin.Context) (int, string) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return http.StatusUnauthorized, "Authorization header required"
+	}
+
+	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+	claims, err := handlers.VerifyToken(tokenString)
+	if err != nil {
+		return http.StatusUnauthorized, "Invalid token: " + err.Error()
+	}
+
+	sessionID := claims["sid"].(string)
+	jti := claims["jti"].(string)
+
+	// Reject tokens whose session was logged out or whose id was blocked
+	revoked, err := cache.IsTokenRevoked(c.Request.Context(), sessionID, jti)
+	if err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+		return http.StatusServiceUnavailable, "Unable to verify token"
+	}
+	if revoked {
+		return http.StatusUnauthorized, "Token has been revoked"
+	}
+
+	userID := uint(claims["user_id"].(float64))
+
+	// Record activity in Redis only, the sessions endpoint merges it on read
+	if err := cache.TouchSession(c.Request.Context(), userID, sessionID); err != nil {
+		log.Printf("Failed to update session activity: %v", err)
+	}
+
+	role, _ := claims["role"].(string)
+
+	c.Set("user_id", userID)
+	c.Set("role", role)
+	c.Set("session_id", sessionID)
+	c.Set("token_jti", jti)
+	c.Set("token_expires_at", time.Unix(int64(claims["exp"].(float64)), 0))
+	return http.StatusOK, ""
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"adbiz_backend/cache"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseRecorder keeps a copy of the response body while it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Idempotency makes retries safe for requests that carry an Idempotency-Key header.
+// The first response for a key is stored for ttl and replayed for later requests with
+// the same key and body; server errors are not stored so the client can retry them.
+// It must run after AuthMiddleware so keys are scoped per user.
+func Idempotency(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+		key := fmt.Sprintf("%s:%s %s:%s", clientKey(c), c.Request.Method, c.FullPath(), idempotencyKey)
+
+		ctx := c.Request.Context()
+		reserved, stored, err := cache.ReserveIdempotencyKey(ctx, key)
+		if err != nil {
+			// Without Redis we cannot deduplicate, but the request itself can still run
+			log.Printf("Idempotency store unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		if !reserved {
+			switch {
+			case stored == nil:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			case stored.RequestHash != requestHash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.Status, stored.ContentType, stored.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Clients retry when their connection drops, which also cancels the request
+		// context; the outcome must still be recorded or the key stays pending
+		ctx = context.WithoutCancel(ctx)
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := cache.ReleaseIdempotencyKey(ctx, key); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		resp := &cache.IdempotentResponse{
+			RequestHash: requestHash,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := cache.SaveIdempotentResponse(ctx, key, resp, ttl); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"adbiz_backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequireOwnerOrAdmin resolves the user named by the :mobile_number path parameter
+// and only lets the request through for that user or an admin.
+// The loaded user is stored in the context as "subject_user". It must run after AuthMiddleware.
+func RequireOwnerOrAdmin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mobileNumber := c.Param("mobile_number")
+		if mobileNumber == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile number is required"})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if result := db.Where("mobile_number = ?", mobileNumber).First(&user); result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find user: " + result.Error.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		authUserID, _ := c.Get("user_id")
+		if id, ok := authUserID.(uint); !ok || (id != user.ID && c.GetString("role") != models.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only access your own data"})
+			c.Abort()
+			return
+		}
+
+		c.Set("subject_user", &user)
+		c.Next()
+	}
+}
+
+// LoadSubjectShop loads the shop of the user resolved by RequireOwnerOrAdmin
+// and stores it in the context as "subject_shop"
+func LoadSubjectShop(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("subject_user").(*models.User)
+
+		var shop models.Shop
+		if result := db.Where("user_id = ?", user.ID).First(&shop); result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Shop not found for this user"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find shop: " + result.Error.Error()})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("subject_shop", &shop)
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"adbiz_backend/config"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const RateLimitPrefix = "ratelimit:"
+
+// slidingWindowScript counts requests in a sliding window using Redis server time,
+// so every replica sees the same clock. It returns {allowed, remaining, retry_after_ms}.
+var slidingWindowScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], 0, now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, now .. "-" .. ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+// DefaultRateLimit limits each client to the configured number of requests per second
+func DefaultRateLimit(name string, cfg config.RateLimitConfig) gin.HandlerFunc {
+	return RateLimit(name, cfg.RequestsPerSecond, time.Second)
+}
+
+// RateLimit allows each client at most limit requests per window on the routes it guards.
+// Clients are identified by user ID when AuthMiddleware ran first, then by an API key
+// APIKey recognized, then by IP.
+// Requests over the limit are rejected with 429 instead of being queued.
+func RateLimit(name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := RateLimitPrefix + name + ":" + clientKey(c)
+
+		res, err := slidingWindowScript.Run(c.Request.Context(), config.RedisClient, []string{key},
+			window.Milliseconds(), limit, randomSuffix()).Int64Slice()
+		if err != nil {
+			// Fail open so a Redis outage does not take the API down with it
+			log.Printf("Rate limiter unavailable: %v", err)
+			c.Next()
+			return
+		}
+
+		allowed, remaining, retryAfter := res[0] == 1, res[1], time.Duration(res[2])*time.Millisecond
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+
+		if !allowed {
+			seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+			c.Header("X-RateLimit-Reset", seconds)
+			c.Header("Retry-After", seconds)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(window.Seconds()))))
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller a rate limit applies to
+func clientKey(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists {
+		return fmt.Sprintf("user:%d", userID.(uint))
+	}
+	if keyID, exists := c.Get("api_key_id"); exists {
+		return "key:" + keyID.(string)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// randomSuffix keeps sorted set members unique when requests share a millisecond
+func randomSuffix() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"adbiz_backend/ids"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// goMigrations are data migrations that need application code, such as ID generation.
+// They work on tables rather than models so later model changes do not alter them.
+var goMigrations = []Migration{
+	{Version: 2, Name: "opaque_public_ids", up: assignOpaqueIDs, down: keepOpaqueIDs},
+}
+
+// legacyShopIDAliasTTL is how long replaced ShopIDs keep resolving via /shops/by-id
+const legacyShopIDAliasTTL = 90 * 24 * time.Hour
+
+// assignOpaqueIDs replaces ShopIDs built from shop details with opaque IDs, keeping the
+// old ones resolvable through shop_id_aliases, and fills in missing user and post public IDs
+func assignOpaqueIDs(tx *gorm.DB) error {
+	expiresAt := time.Now().UTC().Add(legacyShopIDAliasTTL)
+
+	var shops []struct {
+		ID     uint
+		ShopID string
+	}
+	if err := tx.Table("shops").Select("id", "shop_id").Scan(&shops).Error; err != nil {
+		return err
+	}
+	for _, shop := range shops {
+		if ids.IsValid(shop.ShopID) {
+			continue
+		}
+		if err := tx.Exec(`INSERT INTO shop_id_aliases (old_shop_id, shop_ref_id, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT DO NOTHING`, shop.ShopID, shop.ID, expiresAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("shops").Where("id = ?", shop.ID).Update("shop_id", ids.New()).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"users", "posts"} {
+		var rowIDs []uint
+		if err := tx.Table(table).Where("public_id IS NULL OR public_id = ''").Pluck("id", &rowIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range rowIDs {
+			if err := tx.Table(table).Where("id = ?", id).Update("public_id", ids.New()).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepOpaqueIDs leaves the IDs in place on rollback; they stay valid and old ShopIDs
+// still resolve through their aliases
+func keepOpaqueIDs(tx *gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,304 @@
+// Package migrations versions the database schema. Migrations are numbered SQL files in
+// sql/ (NNNN_name.up.sql with an optional NNNN_name.down.sql), embedded in the binary,
+// plus data migrations written in Go. Each runs in its own transaction together with its
+// row in schema_migrations, and a Postgres advisory lock keeps replicas from running
+// them concurrently.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// lockKey identifies the advisory lock held while migrating
+const lockKey int64 = 0x6164_6269_7a5f_6d69 // "adbiz_mi"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrIrreversible is returned when rolling back a migration without a down step
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migration is one step of the schema history
+type Migration struct {
+	Version int
+	Name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// Status is a migration and when it was applied, nil while pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of schema_migrations
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// All returns every known migration ordered by version
+func All() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := sqlFiles.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = execSQL(string(content))
+		} else {
+			m.down = execSQL(string(content))
+		}
+	}
+
+	for _, gm := range goMigrations {
+		if _, ok := byVersion[gm.Version]; ok {
+			return nil, fmt.Errorf("migration %d is defined twice", gm.Version)
+		}
+		gm := gm
+		byVersion[gm.Version] = &gm
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+func execSQL(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		// Without arguments the statements are sent in one simple-protocol round trip
+		return tx.Exec(statements).Error
+	}
+}
+
+// Up applies pending migrations in order, at most steps of them when steps > 0,
+// and returns the ones it applied
+func Up(ctx context.Context, db *gorm.DB, steps int) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if steps > 0 && len(done) == steps {
+				break
+			}
+
+			log.Printf("Applying migration %d_%s", m.Version, m.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and returns them
+func Down(ctx context.Context, db *gorm.DB, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1")
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]Migration, len(all))
+	for _, m := range all {
+		known[m.Version] = m
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *gorm.DB) error {
+		var rows []schemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			m, ok := known[row.Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is applied but unknown to this binary", row.Version, row.Name)
+			}
+			if m.down == nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, ErrIrreversible)
+			}
+
+			log.Printf("Rolling back migration %d_%s", m.Version, m.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Statuses returns every known migration with the time it was applied
+func Statuses(ctx context.Context, db *gorm.DB) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	conn := db.WithContext(ctx)
+	if err := ensureTable(conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(all))
+	for i, m := range all {
+		statuses[i] = Status{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that are not applied yet
+func Pending(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	statuses, err := Statuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// CountPending returns how many known migrations are not applied yet. Unlike Pending
+// it only reads, so it is cheap enough for readiness probes.
+func CountPending(ctx context.Context, db *gorm.DB) (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(db.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, m := range all {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+// Other instances block until the lock is released, then see the migrations as applied.
+func withLock(ctx context.Context, db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Use a fresh context so the lock is released even if ctx was cancelled
+			if err := conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err != nil {
+				log.Printf("Failed to release migration lock: %v", err)
+			}
+		}()
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+func appliedVersions(db *gorm.DB) (map[int]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS post_stats;
+DROP TABLE IF EXISTS shop_stats;
+DROP TABLE IF EXISTS media;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS shop_id_aliases;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS shops;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,209 @@
+-- Schema as previously created by AutoMigrate and the data migrations at boot.
+-- Every statement is idempotent so databases set up that way adopt it: tables that
+-- already exist get the columns added since they were created before any index uses them.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    public_id varchar(26),
+    mobile_number text NOT NULL,
+    name text NOT NULL,
+    email text,
+    role text NOT NULL,
+    profile_photo text
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS public_id varchar(26);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_mobile_number ON users (mobile_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_public_id ON users (public_id);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS shops (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    shop_id text NOT NULL,
+    shop_name text NOT NULL,
+    shop_username text NOT NULL,
+    bio text,
+    product_type text NOT NULL,
+    location text,
+    address_line1 text,
+    address_line2 text,
+    address_city text,
+    address_state text,
+    address_postal_code text,
+    address_country varchar(2),
+    latitude decimal,
+    longitude decimal,
+    shop_photo text,
+    user_id bigint NOT NULL,
+    CONSTRAINT fk_shops_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+ALTER TABLE shops
+    ADD COLUMN IF NOT EXISTS address_line1 text,
+    ADD COLUMN IF NOT EXISTS address_line2 text,
+    ADD COLUMN IF NOT EXISTS address_city text,
+    ADD COLUMN IF NOT EXISTS address_state text,
+    ADD COLUMN IF NOT EXISTS address_postal_code text,
+    ADD COLUMN IF NOT EXISTS address_country varchar(2),
+    ADD COLUMN IF NOT EXISTS latitude decimal,
+    ADD COLUMN IF NOT EXISTS longitude decimal;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_user_id ON shops (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_shop_id ON shops (shop_id);
+CREATE INDEX IF NOT EXISTS idx_shops_city ON shops (address_city);
+CREATE INDEX IF NOT EXISTS idx_shops_product_type ON shops (product_type);
+CREATE INDEX IF NOT EXISTS idx_shops_deleted_at ON shops (deleted_at);
+
+-- Shop usernames are unique in any letter case; rename later duplicates, keeping the oldest
+UPDATE shops SET shop_username = shops.shop_username || '_' || shops.id
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY LOWER(shop_username) ORDER BY id) AS rn
+    FROM shops
+) d
+WHERE d.id = shops.id AND d.rn > 1;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shops_shop_username_lower ON shops (LOWER(shop_username));
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id varchar(64) PRIMARY KEY,
+    user_id bigint NOT NULL,
+    device_name text,
+    user_agent text,
+    ip text,
+    created_at timestamptz,
+    last_seen_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id bigint,
+    followee_id bigint,
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT fk_follows_follower FOREIGN KEY (follower_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_follows_followee FOREIGN KEY (followee_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_created_at ON follows (created_at);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    public_id varchar(26),
+    shop_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    title text NOT NULL,
+    description text,
+    price bigint NOT NULL,
+    currency varchar(3) NOT NULL,
+    product_type text NOT NULL,
+    media text[],
+    status text NOT NULL,
+    published_at timestamptz,
+    expires_at timestamptz,
+    CONSTRAINT fk_posts_shop FOREIGN KEY (shop_id) REFERENCES shops (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS public_id varchar(26);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_public_id ON posts (public_id);
+CREATE INDEX IF NOT EXISTS idx_posts_shop_id ON posts (shop_id);
+CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id);
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS shop_id_aliases (
+    old_shop_id text PRIMARY KEY,
+    shop_ref_id bigint NOT NULL,
+    expires_at timestamptz NOT NULL,
+    CONSTRAINT fk_shop_id_aliases_shop FOREIGN KEY (shop_ref_id) REFERENCES shops (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_shop_id_aliases_shop_ref_id ON shop_id_aliases (shop_ref_id);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id bigserial PRIMARY KEY,
+    slug varchar(50) NOT NULL,
+    parent_id bigint,
+    names jsonb NOT NULL,
+    icon text,
+    position bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+-- The product types the app started with; existing shops and posts get their letter case
+INSERT INTO categories (slug, names, position, created_at, updated_at) VALUES
+    ('food', '{"en": "Food"}', 1, now(), now()),
+    ('clothes', '{"en": "Clothes"}', 2, now(), now()),
+    ('beauty', '{"en": "Beauty"}', 3, now(), now()),
+    ('healthcare', '{"en": "Healthcare"}', 4, now(), now())
+ON CONFLICT (slug) DO NOTHING;
+UPDATE shops SET product_type = c.slug
+FROM categories c
+WHERE LOWER(TRIM(shops.product_type)) = c.slug AND shops.product_type <> c.slug;
+UPDATE posts SET product_type = c.slug
+FROM categories c
+WHERE LOWER(TRIM(posts.product_type)) = c.slug AND posts.product_type <> c.slug;
+
+CREATE TABLE IF NOT EXISTS media (
+    id varchar(26) PRIMARY KEY,
+    user_id bigint NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    width bigint NOT NULL,
+    height bigint NOT NULL,
+    keys jsonb NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_media_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
+
+-- Stats tables have no foreign keys so events of deleted shops never fail a rollup
+CREATE TABLE IF NOT EXISTS shop_stats (
+    shop_id bigint,
+    period varchar(4),
+    bucket_start timestamptz,
+    profile_views bigint NOT NULL DEFAULT 0,
+    post_impressions bigint NOT NULL DEFAULT 0,
+    post_clicks bigint NOT NULL DEFAULT 0,
+    follows bigint NOT NULL DEFAULT 0,
+    unfollows bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (shop_id, period, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS post_stats (
+    post_id bigint,
+    day timestamptz,
+    shop_id bigint NOT NULL,
+    impressions bigint NOT NULL DEFAULT 0,
+    clicks bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, day)
+);
+CREATE INDEX IF NOT EXISTS idx_post_stats_shop_day ON post_stats (shop_id, day);
+
+-- Full-text search: generated vectors change in the same transaction as the row, and
+-- trigram indexes make matching typo tolerant
+ALTER TABLE shops ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(shop_name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(shop_username, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(bio, '')), 'B')
+) STORED;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_shops_search_vector ON shops USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_shops_shop_name_trgm ON shops USING GIN (shop_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_shops_shop_username_trgm ON shops USING GIN (shop_username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_posts_title_trgm ON posts USING GIN (title gin_trgm_ops);
@@ -0,0 +1,39 @@
+-- Rebuild the legacy lists from the follow graph. The data_migrations markers are not
+-- restored; nothing reads them any more.
+CREATE TABLE fav1 (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    fav bigint NOT NULL,
+    fav_list text[],
+    user_id bigint NOT NULL,
+    CONSTRAINT fk_fav1_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX idx_fav1_user_id ON fav1 (user_id);
+CREATE INDEX idx_fav1_deleted_at ON fav1 (deleted_at);
+
+CREATE TABLE fav2 (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    fav bigint NOT NULL,
+    fav_list text[],
+    user_id bigint NOT NULL,
+    CONSTRAINT fk_fav2_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX idx_fav2_user_id ON fav2 (user_id);
+CREATE INDEX idx_fav2_deleted_at ON fav2 (deleted_at);
+
+INSERT INTO fav1 (created_at, updated_at, fav, fav_list, user_id)
+SELECT MIN(f.created_at), MAX(f.created_at), COUNT(*), array_agg(u.mobile_number ORDER BY f.created_at), f.follower_id
+FROM follows f
+JOIN users u ON u.id = f.followee_id
+GROUP BY f.follower_id;
+
+INSERT INTO fav2 (created_at, updated_at, fav, fav_list, user_id)
+SELECT MIN(f.created_at), MAX(f.created_at), COUNT(*), array_agg(u.mobile_number ORDER BY f.created_at), f.followee_id
+FROM follows f
+JOIN users u ON u.id = f.follower_id
+GROUP BY f.followee_id;
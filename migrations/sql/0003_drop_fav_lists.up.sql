@@ -0,0 +1,34 @@
+-- Copy any follows still only recorded in the legacy Fav1 (following) and Fav2 (followers)
+-- mobile number lists into the follow graph, then drop them together with the marker
+-- table of the old boot-time data migrations.
+--
+-- GORM named the legacy tables fav1 and fav2, not fav1s and fav2s. The boot-time backfill
+-- read the latter, so it failed and rolled back without marking itself applied; no
+-- database has a partial backfill, and this migration is the one that copies the lists.
+DO $$
+BEGIN
+    IF to_regclass('fav1') IS NOT NULL THEN
+        INSERT INTO follows (follower_id, followee_id, created_at)
+        SELECT f.user_id, u.id, f.updated_at
+        FROM fav1 f
+        CROSS JOIN LATERAL unnest(f.fav_list) AS m(mobile_number)
+        JOIN users u ON u.mobile_number = m.mobile_number
+        WHERE f.deleted_at IS NULL AND u.id <> f.user_id
+        ON CONFLICT DO NOTHING;
+    END IF;
+
+    IF to_regclass('fav2') IS NOT NULL THEN
+        INSERT INTO follows (follower_id, followee_id, created_at)
+        SELECT u.id, f.user_id, f.updated_at
+        FROM fav2 f
+        CROSS JOIN LATERAL unnest(f.fav_list) AS m(mobile_number)
+        JOIN users u ON u.mobile_number = m.mobile_number
+        WHERE f.deleted_at IS NULL AND u.id <> f.user_id
+        ON CONFLICT DO NOTHING;
+    END IF;
+END
+$$;
+
+DROP TABLE IF EXISTS fav1;
+DROP TABLE IF EXISTS fav2;
+DROP TABLE IF EXISTS data_migrations;
@@ -1,45 +1,235 @@
 package models
 
 import (
+	"adbiz_backend/ids"
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/lib/pq"
 )
 
+// User roles, carried in the "role" claim of access tokens
+const (
+	RoleBuyer  = "buyer"
+	RoleSeller = "seller"
+	RoleAdmin  = "admin"
+)
+
+// Model is gorm.Model with the numeric ID kept out of JSON. Responses identify users,
+// shops and posts by their opaque public IDs so that sequential IDs cannot be enumerated.
+type Model struct {
+	ID        uint `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
 type User struct {
-	gorm.Model
-	MobileNumber string  `gorm:"uniqueIndex;not null" json:"mobile_number"`
-	Name         string  `gorm:"not null" json:"name"`
-	Email        *string `json:"email,omitempty"`
-	Role         string  `gorm:"not null" json:"role"`
-	ProfilePhoto *string `json:"profile_photo,omitempty"`
+	Model
+	PublicID        string  `gorm:"uniqueIndex;size:26" json:"public_id"`
+	MobileNumber    string  `gorm:"uniqueIndex;not null" json:"mobile_number"`
+	Name            string  `gorm:"not null" json:"name"`
+	Email           *string `json:"email,omitempty"`
+	Role            string  `gorm:"not null" json:"role"`
+	ProfilePhoto    *string `json:"profile_photo,omitempty"`              // Media ID; older rows may hold a URL
+	ProfilePhotoURL *string `gorm:"-" json:"profile_photo_url,omitempty"` // Signed download URL, set by handlers
 }
 
 type Shop struct {
-	gorm.Model
-	ShopID       string  `gorm:"uniqueIndex;not null" json:"shop_id"`
-	ShopName     string  `gorm:"not null" json:"shop_name"`
-	ShopUsername string  `gorm:"not null" json:"shop_username"`
-	Bio          *string `json:"bio,omitempty"`
-	ProductType  string  `gorm:"not null" json:"product_type"`
-	Location     *string `json:"location,omitempty"`
-	ShopPhoto    *string `json:"shop_photo,omitempty"`
-	UserID       uint    `gorm:"not null;uniqueIndex" json:"userid"`                     // Ensures one shop per user
-	User         User    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // Foreign key constraint
-}
-
-type Fav1 struct {
-	gorm.Model
-	Fav     int            `gorm:"not null" json:"fav"`
-	FavList pq.StringArray `gorm:"type:text[]" json:"favlist"`         // List of mobile numbers
-	UserID  uint           `gorm:"not null;uniqueIndex" json:"userid"` // One fav1 per user
-	User    User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-}
-
-type Fav2 struct {
-	gorm.Model
-	Fav     int            `gorm:"not null" json:"fav"`
-	FavList pq.StringArray `gorm:"type:text[]" json:"favlist"`         // List of mobile numbers
-	UserID  uint           `gorm:"not null;uniqueIndex" json:"userid"` // One fav2 per user
-	User    User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Model
+	ShopID       string   `gorm:"uniqueIndex;not null" json:"shop_id"`
+	ShopName     string   `gorm:"not null" json:"shop_name"`
+	ShopUsername string   `gorm:"not null" json:"shop_username"`
+	Bio          *string  `json:"bio,omitempty"`
+	ProductType  string   `gorm:"not null;index" json:"product_type"` // Category slug
+	Location     *string  `json:"location,omitempty"`
+	Address      Address  `gorm:"embedded;embeddedPrefix:address_" json:"address"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	ShopPhoto    *string  `json:"shop_photo,omitempty"`                                   // Media ID; older rows may hold a URL
+	ShopPhotoURL *string  `gorm:"-" json:"shop_photo_url,omitempty"`                      // Signed download URL, set by handlers
+	UserID       uint     `gorm:"not null;uniqueIndex" json:"-"`                          // Ensures one shop per user
+	User         User     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"` // Foreign key constraint
+}
+
+// Address is the structured postal address of a shop
+type Address struct {
+	Line1      *string `json:"line1,omitempty"`
+	Line2      *string `json:"line2,omitempty"`
+	City       *string `gorm:"index" json:"city,omitempty"`
+	State      *string `json:"state,omitempty"`
+	PostalCode *string `json:"postal_code,omitempty"`
+	Country    *string `gorm:"size:2" json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+}
+
+// HasCoordinates reports whether the shop can be found by location
+func (s *Shop) HasCoordinates() bool {
+	return s.Latitude != nil && s.Longitude != nil
+}
+
+// BeforeCreate assigns the opaque public ID
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.PublicID == "" {
+		u.PublicID = ids.New()
+	}
+	return nil
+}
+
+// BeforeCreate assigns the opaque shop ID
+func (s *Shop) BeforeCreate(tx *gorm.DB) error {
+	if s.ShopID == "" {
+		s.ShopID = ids.New()
+	}
+	return nil
+}
+
+// ShopIDAlias keeps a replaced ShopID resolvable until ExpiresAt
+type ShopIDAlias struct {
+	OldShopID string    `gorm:"primaryKey"`
+	ShopRefID uint      `gorm:"not null;index"` // shops.id
+	ExpiresAt time.Time `gorm:"not null"`
+	Shop      Shop      `gorm:"foreignKey:ShopRefID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// Session is a login on one device; its ID is the "sid" claim of the JWTs issued for it
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	User       User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Follow is an edge of the follow graph: FollowerID follows FolloweeID
+type Follow struct {
+	FollowerID uint      `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	FolloweeID uint      `gorm:"primaryKey;autoIncrement:false;index" json:"-"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+	Follower   User      `gorm:"foreignKey:FollowerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Followee   User      `gorm:"foreignKey:FolloweeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Category is a node of the product type taxonomy. Shops and posts store its Slug
+// as their ProductType.
+type Category struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	Slug      string            `gorm:"uniqueIndex;size:50;not null" json:"slug"`
+	ParentID  *uint             `gorm:"index" json:"parent_id,omitempty"`
+	Names     map[string]string `gorm:"type:jsonb;serializer:json;not null" json:"names"` // Display names keyed by language code, "en" is always set
+	Icon      *string           `json:"icon,omitempty"`
+	Position  int               `gorm:"not null;default:0" json:"position"` // Sort order among siblings
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Parent    *Category         `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+}
+
+// DisplayName returns the name of the category in lang, falling back to English
+func (c *Category) DisplayName(lang string) string {
+	if name, ok := c.Names[lang]; ok {
+		return name
+	}
+	if name, ok := c.Names["en"]; ok {
+		return name
+	}
+	return c.Slug
+}
+
+// Media is an uploaded image. Its original and thumbnails are stored in the BlobStore
+// under Keys, and profiles, shops and posts refer to it by ID.
+type Media struct {
+	ID          string            `gorm:"primaryKey;size:26" json:"id"`
+	UserID      uint              `gorm:"not null;index" json:"-"` // Uploader, the only user who may attach it
+	ContentType string            `gorm:"not null" json:"content_type"`
+	Size        int64             `gorm:"not null" json:"size"` // Bytes of the stored original
+	Width       int               `gorm:"not null" json:"width"`
+	Height      int               `gorm:"not null" json:"height"`
+	Keys        map[string]string `gorm:"type:jsonb;serializer:json;not null" json:"-"` // Blob keys by variant name, including "original"
+	CreatedAt   time.Time         `json:"created_at"`
+	User        User              `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// BeforeCreate assigns the opaque media ID
+func (m *Media) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = ids.New()
+	}
+	return nil
+}
+
+// Post statuses; only published posts that have not expired are visible to buyers
+const (
+	PostStatusDraft     = "draft"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
+// Post is an advertisement published by a shop
+type Post struct {
+	Model
+	PublicID    string         `gorm:"uniqueIndex;size:26" json:"public_id"`
+	ShopID      uint           `gorm:"not null;index" json:"-"`
+	UserID      uint           `gorm:"not null;index" json:"-"` // Owner of the shop, kept for ownership checks
+	Title       string         `gorm:"not null" json:"title"`
+	Description *string        `json:"description,omitempty"`
+	Price       int64          `gorm:"not null" json:"price"` // In the smallest unit of Currency
+	Currency    string         `gorm:"size:3;not null" json:"currency"`
+	ProductType string         `gorm:"not null" json:"product_type"`  // Category slug
+	Media       pq.StringArray `gorm:"type:text[]" json:"media"`      // Media IDs
+	MediaURLs   []string       `gorm:"-" json:"media_urls,omitempty"` // Signed download URLs in the order of Media, set by handlers
+	Status      string         `gorm:"not null;index" json:"status"`
+	PublishedAt *time.Time     `json:"published_at,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	Shop        Shop           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// BeforeCreate assigns the opaque public ID
+func (p *Post) BeforeCreate(tx *gorm.DB) error {
+	if p.PublicID == "" {
+		p.PublicID = ids.New()
+	}
+	return nil
+}
+
+// IsVisible reports whether buyers can see the post at the given time
+func (p *Post) IsVisible(now time.Time) bool {
+	return p.Status == PostStatusPublished && (p.ExpiresAt == nil || p.ExpiresAt.After(now))
+}
+
+// Rollup periods of ShopStat
+const (
+	StatPeriodHour = "hour"
+	StatPeriodDay  = "day"
+)
+
+// StatCounts are the analytics counters of a shop
+type StatCounts struct {
+	ProfileViews    int64 `gorm:"not null;default:0" json:"profile_views"`
+	PostImpressions int64 `gorm:"not null;default:0" json:"post_impressions"` // Posts shown in feeds, search results and the shop profile
+	PostClicks      int64 `gorm:"not null;default:0" json:"post_clicks"`      // Post detail views
+	Follows         int64 `gorm:"not null;default:0" json:"follows"`
+	Unfollows       int64 `gorm:"not null;default:0" json:"unfollows"`
+}
+
+// ShopStat is the rollup of a shop's analytics events for one UTC hour or day.
+// Stats tables have no foreign keys so events of deleted shops never fail a rollup.
+type ShopStat struct {
+	ShopID      uint      `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Period      string    `gorm:"primaryKey;size:4" json:"-"` // StatPeriodHour or StatPeriodDay
+	BucketStart time.Time `gorm:"primaryKey" json:"bucket_start"`
+	StatCounts  `gorm:"embedded"`
+}
+
+// PostStat is the rollup of a post's engagement for one UTC day
+type PostStat struct {
+	PostID      uint      `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Day         time.Time `gorm:"primaryKey;index:idx_post_stats_shop_day,priority:2" json:"day"`
+	ShopID      uint      `gorm:"not null;index:idx_post_stats_shop_day,priority:1" json:"-"`
+	Impressions int64     `gorm:"not null;default:0" json:"impressions"`
+	Clicks      int64     `gorm:"not null;default:0" json:"clicks"`
 }
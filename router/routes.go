@@ -3,52 +3,147 @@ package router
 import (
 	"adbiz_backend/config"
 	"adbiz_backend/handlers"
+	"adbiz_backend/metrics"
 	"adbiz_backend/middleware"
+	"adbiz_backend/models"
+	"adbiz_backend/storage"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // @BasePath /api/v1
-func SetupRouter() *gin.Engine {
-	r := config.SetupServer()
+func SetupRouter(cfg *config.Config, healthHandler *handlers.HealthHandler) *gin.Engine {
+	r := config.SetupServer(cfg)
+	r.Use(middleware.Metrics())
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(config.Db)
+	authHandler := handlers.NewAuthHandler(config.Db, cfg)
 	favHandler := handlers.NewFevHandler(config.Db)
+	postHandler := handlers.NewPostHandler(config.Db, cfg)
+	shopHandler := handlers.NewShopHandler(config.Db)
+	categoryHandler := handlers.NewCategoryHandler(config.Db)
+	searchHandler := handlers.NewSearchHandler(config.Db)
+	mediaHandler := handlers.NewMediaHandler(config.Db, storage.Blobs, cfg)
+
+	// Liveness and readiness probes, outside the API and its rate limits
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Prometheus scrape endpoint; keep it off the public load balancer
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Public keys for verifying access tokens in other services
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
+
+	// Uploaded files kept on the local filesystem, behind signed URLs
+	if _, ok := storage.Blobs.(*storage.LocalStore); ok {
+		r.GET("/media/*key", mediaHandler.ServeLocalMedia)
+		r.HEAD("/media/*key", mediaHandler.ServeLocalMedia)
+	}
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
+	v1.Use(middleware.APIKey(cfg.RateLimit.APIKeys))
 	{
-		// Public routes - Mobile verification and registration flow
-		v1.POST("/verify-mobile", authHandler.VerifyMobile)            // Step 1: Verify if mobile exists
-		v1.POST("/register-basic", authHandler.RegisterBasicInfo)      // Step 2: Register basic info
-		v1.POST("/register-seller", authHandler.RegisterSellerDetails) // Step 3: Register seller details
+		// Stricter per-client limits for routes that send or check OTPs
+		otpRequestLimit := middleware.RateLimit("otp-request", 5, 15*time.Minute)
+		otpVerifyLimit := middleware.RateLimit("otp-verify", 20, 15*time.Minute)
 
-		// Legacy routes (can be kept for backward compatibility)
-		v1.POST("/register", authHandler.Register)
-		v1.POST("/login", authHandler.Login)
+		// Public routes, limited per API key or IP
+		public := v1.Group("/")
+		public.Use(middleware.DefaultRateLimit("public", cfg.RateLimit))
+		{
+			// Mobile verification and registration flow
+			public.POST("/request-otp", otpRequestLimit, authHandler.RequestOTP)    // Step 1: Send OTP to mobile
+			public.POST("/verify-mobile", otpVerifyLimit, authHandler.VerifyMobile) // Step 2: Verify OTP and check if mobile exists
+			public.POST("/register-basic", authHandler.RegisterBasicInfo)           // Step 3: Register basic info
+			public.POST("/register-seller", authHandler.RegisterSellerDetails)      // Step 4: Register seller details
 
-		// Favorite routes
-		v1.POST("/fav", favHandler.HandleFav) // Handle user favorites
+			// Token refresh with rotating refresh tokens
+			public.POST("/auth/refresh", authHandler.RefreshToken)
+
+			// Category taxonomy and browsing
+			public.GET("/categories", categoryHandler.ListCategories)
+			public.GET("/categories/:slug/shops", categoryHandler.GetCategoryShops)
+
+			// Legacy routes (can be kept for backward compatibility)
+			public.POST("/register", authHandler.Register)
+			public.POST("/login", otpVerifyLimit, authHandler.Login)
+		}
+
+		// Routes open to everyone that behave differently for signed-in callers
+		optional := v1.Group("/")
+		optional.Use(middleware.OptionalAuth(), middleware.DefaultRateLimit("public", cfg.RateLimit))
+		{
+			// Reactivation works with the owner's token, an admin token or an OTP
+			optional.POST("/user/reactivate/:mobile_number", otpVerifyLimit, authHandler.ReactivateUser)
+			optional.POST("/user/shop/reactivate/:mobile_number", otpVerifyLimit, authHandler.ReactivateShop)
 
-		v1.POST("/user/reactivate/:mobile_number", authHandler.ReactivateUser)
-		v1.POST("/user/shop/reactivate/:mobile_number", authHandler.ReactivateShop)
+			// Published posts are public, drafts are visible to their owner
+			optional.GET("/posts/:id", postHandler.GetPost)
+
+			// Public shop profiles
+			optional.GET("/shops/:shop_username", shopHandler.GetShopProfile)
+			optional.GET("/shops/by-id/:shop_id", shopHandler.GetShopByID)
+			optional.GET("/shops/nearby", shopHandler.GetNearbyShops)
+
+			// Full-text search across shops and published posts
+			optional.GET("/search", searchHandler.Search)
+
+			// Uploaded images by media ID
+			optional.GET("/media/:id", mediaHandler.GetMedia)
+		}
 
 		// Protected routes
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(), middleware.DefaultRateLimit("user", cfg.RateLimit))
 		{
-			// User routes
-			protected.GET("/user/:mobile_number", authHandler.GetUser)
-			protected.PUT("/user/:mobile_number", authHandler.UpdateUser)
-			protected.GET("/user/shop/:mobile_number", authHandler.GetShopByUserMobile)
-			protected.PUT("/user/shop/:mobile_number", authHandler.UpdateShop)
-			protected.DELETE("/user/:mobile_number", authHandler.DeleteUser)
-			protected.DELETE("/user/shop/:mobile_number", authHandler.DeleteShop)
-			protected.GET("/user/favs/:mobile_number", authHandler.GetFavs)
-			protected.GET("/users", authHandler.GetAllUsers)            //get all users in database
+			// Session routes
+			protected.POST("/auth/logout", authHandler.Logout)
+			protected.POST("/auth/logout-all", authHandler.LogoutAll)
+			protected.GET("/sessions", authHandler.ListSessions)
+			protected.DELETE("/sessions/:id", authHandler.DeleteSession)
+
+			// User routes, restricted to the owner of :mobile_number or an admin
+			owner := middleware.RequireOwnerOrAdmin(config.Db)
+			ownerShop := middleware.LoadSubjectShop(config.Db)
+			protected.GET("/user/:mobile_number", owner, authHandler.GetUser)
+			protected.PUT("/user/:mobile_number", owner, authHandler.UpdateUser)
+			protected.GET("/user/shop/:mobile_number", owner, ownerShop, authHandler.GetShopByUserMobile)
+			protected.PUT("/user/shop/:mobile_number", owner, ownerShop, authHandler.UpdateShop)
+			protected.DELETE("/user/:mobile_number", owner, authHandler.DeleteUser)
+			protected.DELETE("/user/shop/:mobile_number", owner, ownerShop, authHandler.DeleteShop)
+			protected.GET("/user/favs/:mobile_number", owner, authHandler.GetFavs)
 			protected.POST("/favusers", authHandler.GetAllFavUsersInfo) //get all favusersinfo
 
+			// Follow graph routes; retried follows are deduplicated by Idempotency-Key
+			idempotent := middleware.Idempotency(24 * time.Hour)
+			protected.POST("/fav", idempotent, favHandler.HandleFav) // Handle user favorites
+			protected.DELETE("/fav", idempotent, favHandler.HandleUnfav)
+			protected.GET("/users/:id/followers", favHandler.GetFollowers)
+			protected.GET("/users/:id/following", favHandler.GetFollowing)
+
+			// Post routes, limited to the seller owning the post or an admin
+			protected.POST("/posts", postHandler.CreatePost)
+			protected.PUT("/posts/:id", postHandler.UpdatePost)
+			protected.DELETE("/posts/:id", postHandler.DeletePost)
+			protected.GET("/user/shop/:mobile_number/posts", owner, ownerShop, postHandler.GetShopPosts)
+			protected.GET("/user/shop/:mobile_number/analytics", owner, ownerShop, shopHandler.GetShopAnalytics)
+
+			// Home feed of posts from followed shops
+			protected.GET("/feed", postHandler.GetFeed)
+
+			// Image uploads for profile, shop and post photos
+			protected.POST("/media", middleware.RateLimit("media-upload", 30, time.Hour), mediaHandler.UploadMedia)
+
+			// Admin routes
+			admin := middleware.RequireRole(models.RoleAdmin)
+			protected.GET("/users", admin, authHandler.GetAllUsers) //get all users in database
+			protected.POST("/categories", admin, categoryHandler.CreateCategory)
+			protected.PUT("/categories/:slug", admin, categoryHandler.UpdateCategory)
+			protected.DELETE("/categories/:slug", admin, categoryHandler.DeleteCategory)
+
 		}
 	}
 	return r
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"adbiz_backend/config"
+	"adbiz_backend/handlers"
+	"adbiz_backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// seedSeller is a demo seller with a shop and its posts
+type seedSeller struct {
+	user  models.User
+	shop  models.Shop
+	posts []models.Post
+}
+
+func strPtr(s string) *string     { return &s }
+func floatPtr(f float64) *float64 { return &f }
+
+var seedBuyer = models.User{MobileNumber: "+910000000001", Name: "Demo Buyer", Role: models.RoleBuyer}
+
+var seedSellers = []seedSeller{
+	{
+		user: models.User{MobileNumber: "+910000000002", Name: "Demo Baker", Role: models.RoleSeller},
+		shop: models.Shop{
+			ShopName:     "Demo Bakery",
+			ShopUsername: "demo_bakery",
+			Bio:          strPtr("Fresh bread and cakes every morning"),
+			ProductType:  "food",
+			Address:      models.Address{City: strPtr("Bengaluru"), Country: strPtr("IN")},
+			Latitude:     floatPtr(12.9716),
+			Longitude:    floatPtr(77.5946),
+		},
+		posts: []models.Post{
+			{Title: "Sourdough loaf", Description: strPtr("Baked today"), Price: 18000, Currency: "INR", ProductType: "food"},
+			{Title: "Chocolate cake", Description: strPtr("1 kg, order a day ahead"), Price: 95000, Currency: "INR", ProductType: "food"},
+		},
+	},
+	{
+		user: models.User{MobileNumber: "+910000000003", Name: "Demo Tailor", Role: models.RoleSeller},
+		shop: models.Shop{
+			ShopName:     "Demo Boutique",
+			ShopUsername: "demo_boutique",
+			Bio:          strPtr("Handmade kurtas and sarees"),
+			ProductType:  "clothes",
+			Address:      models.Address{City: strPtr("Bengaluru"), Country: strPtr("IN")},
+			Latitude:     floatPtr(12.9352),
+			Longitude:    floatPtr(77.6245),
+		},
+		posts: []models.Post{
+			{Title: "Cotton kurta", Description: strPtr("Sizes S to XL"), Price: 120000, Currency: "INR", ProductType: "clothes"},
+		},
+	},
+}
+
+// runSeed adds demo accounts, shops and posts for local development. Accounts that
+// already exist are left alone, so it can be run repeatedly.
+func runSeed(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: adbiz_backend seed")
+		return 2
+	}
+	if cfg.Production() {
+		fmt.Fprintln(os.Stderr, "Refusing to seed demo data in production")
+		return 1
+	}
+
+	return withStores(cfg, func(ctx context.Context) error {
+		db := config.Db.WithContext(ctx)
+
+		buyer := seedBuyer
+		if created, err := seedUser(db, &buyer); err != nil {
+			return err
+		} else if created {
+			fmt.Printf("Created buyer %s\n", buyer.MobileNumber)
+		}
+
+		for _, seller := range seedSellers {
+			user := seller.user
+			created, err := seedUser(db, &user)
+			if err != nil {
+				return err
+			}
+			if created {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					shop := seller.shop
+					shop.UserID = user.ID
+					if err := tx.Create(&shop).Error; err != nil {
+						return fmt.Errorf("failed to create shop %s: %w", shop.ShopUsername, err)
+					}
+
+					now := time.Now().UTC()
+					for i, post := range seller.posts {
+						publishedAt := now.Add(-time.Duration(i) * time.Hour)
+						post.ShopID = shop.ID
+						post.UserID = user.ID
+						post.Status = models.PostStatusPublished
+						post.PublishedAt = &publishedAt
+						if err := tx.Create(&post).Error; err != nil {
+							return fmt.Errorf("failed to create post %q: %w", post.Title, err)
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Created seller %s with shop %s\n", user.MobileNumber, seller.shop.ShopUsername)
+			}
+
+			// The demo buyer follows every demo shop
+			follow := models.Follow{FollowerID: buyer.ID, FolloweeID: user.ID, CreatedAt: time.Now().UTC()}
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+			if result.Error != nil {
+				return fmt.Errorf("failed to follow %s: %w", user.MobileNumber, result.Error)
+			}
+			if result.RowsAffected > 0 {
+				handlers.BackfillFeed(config.Db, buyer.ID, user.ID)
+			}
+		}
+
+		n, err := handlers.ReindexShopLocations(ctx, config.Db)
+		if err != nil {
+			return fmt.Errorf("failed to index shop locations: %w", err)
+		}
+		fmt.Printf("Seeding done, %d shops are findable by location\n", n)
+		return nil
+	})
+}
+
+// seedUser loads the user with the same mobile number into user, or creates it.
+// It reports whether the user was created.
+func seedUser(db *gorm.DB, user *models.User) (bool, error) {
+	err := db.Where("mobile_number = ?", user.MobileNumber).First(user).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to find user %s: %w", user.MobileNumber, err)
+	}
+	if err := db.Create(user).Error; err != nil {
+		return false, fmt.Errorf("failed to create user %s: %w", user.MobileNumber, err)
+	}
+	return true, nil
+}
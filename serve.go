@@ -0,0 +1,110 @@
+package main
+
+import (
+	"adbiz_backend/analytics"
+	"adbiz_backend/config"
+	"adbiz_backend/handlers"
+	"adbiz_backend/router"
+	"adbiz_backend/storage"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runServe starts the HTTP server and blocks until it is interrupted
+func runServe(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		log.Printf("usage: adbiz_backend serve")
+		return 2
+	}
+
+	// Load JWT signing keys, refusing to start in production without one
+	if err := handlers.LoadSigningKeys(cfg); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	if err := handlers.LoadCursorKey(cfg); err != nil {
+		log.Fatalf("Failed to load cursor key: %v", err)
+	}
+
+	// Setup database
+	if err := config.SetupDatabase(cfg); err != nil {
+		log.Fatalf("Failed to setup database: %v", err)
+	}
+
+	// Setup Redis
+	if err := setupRedis(cfg); err != nil {
+		log.Fatalf("Failed to setup Redis: %v", err)
+	}
+	defer config.CloseRedis()
+
+	// Setup the store for uploaded media
+	if err := storage.SetupBlobStore(cfg); err != nil {
+		log.Fatalf("Failed to setup media store: %v", err)
+	}
+
+	// Setup router
+	healthHandler := handlers.NewHealthHandler(config.Db, cfg)
+	router := router.SetupRouter(cfg, healthHandler)
+
+	// Roll up analytics events in the background until shutdown
+	analyticsCtx, stopAnalytics := context.WithCancel(context.Background())
+	analyticsDone := make(chan struct{})
+	go func() {
+		analytics.Run(analyticsCtx, config.Db)
+		close(analyticsDone)
+	}()
+
+	// Create server with timeouts
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		log.Printf("Server starting on http://localhost:%d", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for interrupt or termination signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	// Fail readiness first so load balancers stop routing here while requests still succeed
+	log.Printf("Draining for %s before shutdown...", cfg.Server.ShutdownDelay)
+	healthHandler.StartDraining()
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	// Graceful shutdown
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatalf("Server forced to shutdown: %v", err)
+	}
+
+	// Flush buffered analytics events once no more requests can add to them
+	stopAnalytics()
+	<-analyticsDone
+
+	log.Println("Server exiting")
+
+	// Close database connection
+	if sqlDB, err := config.Db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	return 0
+}
@@ -0,0 +1,58 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender delivers a text message to a mobile number
+type Sender interface {
+	Send(ctx context.Context, mobileNumber, message string) error
+}
+
+// NewSender returns the Sender of the given kind: "file" appends to path, anything
+// else logs to the console
+func NewSender(kind, path string) Sender {
+	switch kind {
+	case "file":
+		return NewFileSender(path)
+	default:
+		return ConsoleSender{}
+	}
+}
+
+// ConsoleSender writes messages to the application log, for local development
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(ctx context.Context, mobileNumber, message string) error {
+	log.Printf("[SMS] to=%s message=%q", mobileNumber, message)
+	return nil
+}
+
+// FileSender appends messages to a file so tests and scripts can read them back
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, mobileNumber, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open sms outbox: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), mobileNumber, message)
+	return err
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"adbiz_backend/config"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"time"
+)
+
+// ErrNotFound is returned by Get for keys that were never stored or were deleted
+var ErrNotFound = errors.New("blob not found")
+
+// URLTTL is the minimum lifetime of signed URLs, set by SetupBlobStore
+var URLTTL = time.Hour
+
+// BlobStore stores uploaded files under slash-separated keys
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns where clients can download the blob until expires
+	SignedURL(ctx context.Context, key string, expires time.Time) (string, error)
+}
+
+// URLExpiry returns the expiry for URLs signed at now. It is rounded up to a multiple
+// of URLTTL so the same URL is handed out, and can be cached, for a whole window.
+func URLExpiry(now time.Time) time.Time {
+	window := int64(URLTTL / time.Second)
+	return time.Unix((now.Unix()/window+2)*window, 0)
+}
+
+// Blobs is the store selected at startup by SetupBlobStore
+var Blobs BlobStore
+
+// SetupBlobStore creates the BlobStore selected by the media settings
+func SetupBlobStore(cfg *config.Config) error {
+	store, err := NewBlobStore(cfg)
+	if err != nil {
+		return err
+	}
+	Blobs = store
+	URLTTL = cfg.Media.URLTTL
+	return nil
+}
+
+// NewBlobStore returns the BlobStore selected by cfg.Media.Store ("local" or "s3")
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.Media.Store {
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.Media.S3.Endpoint,
+			Region:    cfg.Media.S3.Region,
+			Bucket:    cfg.Media.S3.Bucket,
+			AccessKey: cfg.Media.S3.AccessKey,
+			SecretKey: cfg.Media.S3.SecretKey,
+			UseSSL:    cfg.Media.S3.UseSSL,
+		})
+	default:
+		secret, err := urlSecret(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalStore(cfg.Media.LocalDir, cfg.Media.PublicURL, secret)
+	}
+}
+
+// urlSecret returns the key for signing local media URLs. Outside production a random
+// key is used when none is configured, so URLs stop working when the process restarts.
+func urlSecret(cfg *config.Config) ([]byte, error) {
+	if cfg.Media.URLSecret != "" {
+		return []byte(cfg.Media.URLSecret), nil
+	}
+	if cfg.Production() {
+		return nil, errors.New("MEDIA_URL_SECRET must be set in production")
+	}
+
+	log.Printf("Warning: MEDIA_URL_SECRET not set, using a temporary key")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}